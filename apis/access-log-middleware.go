@@ -0,0 +1,63 @@
+package apis
+
+import (
+	"GolangCourse/commons/apploggers"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// access log middleware, logs every request with the logger of the request
+// so that the correlation id and the principal are logged as well
+// the route template is logged instead of the path, as paths can contain emails
+// requests to excluded path prefixes are not logged, successful requests are logged with the sample rate
+func AccessLogMiddleware(excludedPaths []string, sampleRate float64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			request := c.Request()
+			for _, path := range excludedPaths {
+				if strings.HasPrefix(request.URL.Path, path) {
+					return next(c)
+				}
+			}
+
+			start := time.Now()
+			// errors are handled here, so that the status of the error response is logged
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			status := c.Response().Status
+			if status < http.StatusBadRequest && sampleRate < 1 && rand.Float64() >= sampleRate {
+				return nil
+			}
+
+			bytesIn := request.ContentLength
+			if bytesIn < 0 {
+				bytesIn = 0
+			}
+			_, logger := apploggers.GetLoggerFromEcho(c)
+			fields := []interface{}{
+				"method", request.Method,
+				"route", c.Path(),
+				"status", status,
+				"latency", time.Since(start),
+				"bytes_in", bytesIn,
+				"bytes_out", c.Response().Size,
+				"client_ip", c.RealIP(),
+				"user_agent", request.UserAgent(),
+			}
+			switch {
+			case status >= http.StatusInternalServerError:
+				logger.Errorw("http request", fields...)
+			case status >= http.StatusBadRequest:
+				logger.Warnw("http request", fields...)
+			default:
+				logger.Infow("http request", fields...)
+			}
+			return nil
+		}
+	}
+}
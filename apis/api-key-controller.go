@@ -0,0 +1,109 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"GolangCourse/internals/services"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type akcontroller struct {
+	akservice services.ApiKeyService
+}
+
+func NewApiKeyController(akservice services.ApiKeyService) akcontroller {
+	return akcontroller{
+		akservice: akservice,
+	}
+}
+
+// @Tags API Key Management
+// @Summary CreateApiKey
+// @Description Create api key for machine clients, the key is only returned in this response
+// @Accept json
+// @Produce json
+// @Param payload body models.CreateApiKeyRequest true "Api key data"
+// @Success 201 {object} models.CreatedApiKey
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /admin/api-keys [post]
+func (a *akcontroller) CreateApiKey(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing CreateApiKey")
+	var request *models.CreateApiKeyRequest
+	err := c.Bind(&request)
+	if err != nil || request == nil {
+		return apperrors.NewInvalidArgument("invalid request payload")
+	}
+	if verror := c.Validate(request); verror != nil {
+		return verror
+	}
+	apiKey, serror := a.akservice.CreateApiKey(lcontext, request)
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed CreateApiKey, prefix: %s", apiKey.Prefix)
+	return c.JSON(http.StatusCreated, apiKey)
+}
+
+// @Tags API Key Management
+// @Summary GetApiKeys
+// @Description Get all api keys, keys themselves are never returned
+// @Produce json
+// @Success 200 {array} models.ApiKey
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /admin/api-keys [get]
+func (a *akcontroller) GetApiKeys(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing GetApiKeys")
+	apiKeys, serror := a.akservice.GetApiKeys(lcontext)
+	if serror != nil {
+		return serror
+	}
+	if apiKeys == nil {
+		apiKeys = []*models.ApiKey{}
+	}
+	logger.Infof("Executed GetApiKeys, api keys: %d", len(apiKeys))
+	return c.JSON(http.StatusOK, apiKeys)
+}
+
+// @Tags API Key Management
+// @Summary RevokeApiKey
+// @Description Revoke api key by id, revoked keys are rejected immediately
+// @Produce json
+// @Param id path string true "Api key id"
+// @Success 204
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /admin/api-keys/{id} [delete]
+func (a *akcontroller) RevokeApiKey(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	apiKeyId := c.Param("id")
+	logger.Infof("Executing RevokeApiKey, apiKeyId: %s", apiKeyId)
+	if len(strings.TrimSpace(apiKeyId)) == 0 {
+		return apperrors.NewInvalidArgument("'id' is required")
+	}
+	serror := a.akservice.RevokeApiKey(lcontext, apiKeyId)
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed RevokeApiKey, apiKeyId: %s", apiKeyId)
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"GolangCourse/internals/services"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type acontroller struct {
+	aservice services.AuthService
+}
+
+func NewAuthController(aservice services.AuthService) acontroller {
+	return acontroller{
+		aservice: aservice,
+	}
+}
+
+// @Tags Authentication
+// @Summary Login
+// @Description Login with email and password, returns access token and refresh token
+// @Accept json
+// @Produce json
+// @Param payload body models.LoginRequest true "Credentials"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Router /auth/login [post]
+func (a *acontroller) Login(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing Login")
+	var request *models.LoginRequest
+	err := c.Bind(&request)
+	if err != nil || request == nil {
+		return apperrors.NewInvalidArgument("invalid request payload")
+	}
+	if verror := c.Validate(request); verror != nil {
+		return verror
+	}
+	tokens, serror := a.aservice.Login(lcontext, request)
+	if serror != nil {
+		return serror
+	}
+	logger.Info("Executed Login")
+	return tokenResponse(c, tokens)
+}
+
+// @Tags Authentication
+// @Summary Refresh
+// @Description Exchange refresh token for new access token and refresh token, refresh tokens can only be used once
+// @Accept json
+// @Produce json
+// @Param payload body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Router /auth/refresh [post]
+func (a *acontroller) Refresh(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing Refresh")
+	request, berror := bindRefreshRequest(c)
+	if berror != nil {
+		return berror
+	}
+	tokens, serror := a.aservice.Refresh(lcontext, request.RefreshToken)
+	if serror != nil {
+		return serror
+	}
+	logger.Info("Executed Refresh")
+	return tokenResponse(c, tokens)
+}
+
+// @Tags Authentication
+// @Summary Logout
+// @Description Revoke refresh token, the access token stays valid until it expires
+// @Accept json
+// @Param payload body models.RefreshRequest true "Refresh token"
+// @Success 204
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Router /auth/logout [post]
+func (a *acontroller) Logout(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing Logout")
+	request, berror := bindRefreshRequest(c)
+	if berror != nil {
+		return berror
+	}
+	if serror := a.aservice.Logout(lcontext, request.RefreshToken); serror != nil {
+		return serror
+	}
+	logger.Info("Executed Logout")
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Tags User Management
+// @Summary SetPassword
+// @Description Set password of user, users changing their own password must send the current password
+// @Accept json
+// @Param id path string true "User id"
+// @Param payload body models.PasswordRequest true "Password"
+// @Success 204
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /users/{id}/password [put]
+func (a *acontroller) SetPassword(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	userId := c.Param("id")
+	logger.Infof("Executing SetPassword, userId: %s", userId)
+	if len(strings.TrimSpace(userId)) == 0 {
+		return apperrors.NewInvalidArgument("'id' is required")
+	}
+	var request *models.PasswordRequest
+	err := c.Bind(&request)
+	if err != nil || request == nil {
+		return apperrors.NewInvalidArgument("invalid request payload")
+	}
+	if verror := c.Validate(request); verror != nil {
+		return verror
+	}
+	if serror := a.aservice.SetPassword(lcontext, userId, request); serror != nil {
+		return serror
+	}
+	logger.Infof("Executed SetPassword, userId: %s", userId)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func bindRefreshRequest(c echo.Context) (*models.RefreshRequest, error) {
+	var request *models.RefreshRequest
+	err := c.Bind(&request)
+	if err != nil || request == nil {
+		return nil, apperrors.NewInvalidArgument("invalid request payload")
+	}
+	if verror := c.Validate(request); verror != nil {
+		return nil, verror
+	}
+	return request, nil
+}
+
+// function to write tokens, tokens must not be cached
+func tokenResponse(c echo.Context, tokens *models.TokenResponse) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+	return c.JSON(http.StatusOK, tokens)
+}
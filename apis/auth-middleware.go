@@ -0,0 +1,164 @@
+package apis
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/services"
+	"crypto/x509"
+	"net/netip"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// authentication middleware, validates jwt bearer token, api key or client certificate of the request
+// credentials of the authorization header take precedence, the client certificate is used for requests without header
+// authenticated principal is set in the request context and in the logger of the request
+func AuthMiddleware(verifier *appauth.JwtVerifier, akservice services.ApiKeyService, certificates *appauth.CertificateAuthenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			lcontext, logger := apploggers.GetLoggerFromEcho(c)
+			scheme, credentials, _ := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+			credentials = strings.TrimSpace(credentials)
+			certificate := getClientCertificate(c)
+
+			var principal *appauth.Principal
+			switch {
+			case len(credentials) == 0 && certificate != nil && certificates != nil:
+				var cerror error
+				principal, cerror = certificates.Authenticate(certificate)
+				if cerror != nil {
+					logger.Warnf("client certificate is not allowed, subject: %s", certificate.Subject)
+					return cerror
+				}
+			case len(credentials) == 0:
+				logger.Warn("request without credentials")
+				setAuthenticateHeaders(c, "")
+				return apperrors.NewUnauthorized("bearer token or api key is required")
+			case strings.EqualFold(scheme, "Bearer"):
+				var verror error
+				principal, verror = verifier.Verify(credentials)
+				if verror != nil {
+					logger.Warnf("invalid bearer token, error: %v", verror)
+					setAuthenticateHeaders(c, `error="invalid_token"`)
+					return apperrors.NewUnauthorized("invalid bearer token")
+				}
+			case strings.EqualFold(scheme, "ApiKey"):
+				var aerror error
+				principal, aerror = akservice.Authenticate(lcontext, credentials)
+				if aerror != nil {
+					logger.Warnf("invalid api key, error: %v", aerror)
+					if apperrors.KindOf(aerror) == apperrors.Unauthorized {
+						setAuthenticateHeaders(c, "")
+					}
+					return aerror
+				}
+			default:
+				logger.Warnf("unsupported authorization scheme: %s", scheme)
+				setAuthenticateHeaders(c, "")
+				return apperrors.NewUnauthorized("bearer token or api key is required")
+			}
+
+			lcontext = appauth.WithPrincipal(lcontext, principal)
+			lcontext, _ = apploggers.WithLoggerFields(lcontext, zap.String("principal", principal.String()))
+			c.Set("context", lcontext)
+			c.SetRequest(c.Request().WithContext(appauth.WithPrincipal(c.Request().Context(), principal)))
+			return next(c)
+		}
+	}
+}
+
+// middleware of local development with authentication disabled, requests are made by the system principal
+func LocalPrincipalMiddleware() echo.MiddlewareFunc {
+	principal := appauth.NewSystemPrincipal("local")
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			lcontext, _ := apploggers.GetLoggerFromEcho(c)
+			lcontext = appauth.WithPrincipal(lcontext, principal)
+			lcontext, _ = apploggers.WithLoggerFields(lcontext, zap.String("principal", principal.String()))
+			c.Set("context", lcontext)
+			c.SetRequest(c.Request().WithContext(appauth.WithPrincipal(c.Request().Context(), principal)))
+			return next(c)
+		}
+	}
+}
+
+// role middleware, sets the role of the authenticated principal
+// principals which are not known users are forbidden
+func RoleMiddleware(rservice services.RoleService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			lcontext, logger := apploggers.GetLoggerFromEcho(c)
+			principal := appauth.GetPrincipal(lcontext)
+			if principal == nil {
+				return next(c)
+			}
+			role, rerror := rservice.GetRole(lcontext, principal)
+			if rerror != nil {
+				logger.Warnf("cannot get role of principal, principal: %s, error: %v", principal, rerror)
+				return rerror
+			}
+			principal.Role = role
+			return next(c)
+		}
+	}
+}
+
+// permission middleware, for routes which are not on a single user
+func RequirePermission(permission appauth.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			lcontext, _ := apploggers.GetLoggerFromEcho(c)
+			if aerror := appauth.Authorize(lcontext, permission, ""); aerror != nil {
+				return aerror
+			}
+			return next(c)
+		}
+	}
+}
+
+// mtls middleware, callers of the networks must authenticate with a verified client certificate
+// the address of the connection is checked, as certificates are only verified for direct callers
+func MtlsMiddleware(networks []netip.Prefix) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(networks) == 0 || getClientCertificate(c) != nil {
+				return next(c)
+			}
+			address, err := netip.ParseAddrPort(c.Request().RemoteAddr)
+			if err != nil {
+				return next(c)
+			}
+			for _, network := range networks {
+				if network.Contains(address.Addr().Unmap()) {
+					_, logger := apploggers.GetLoggerFromEcho(c)
+					logger.Warnf("internal caller without client certificate, address: %s", address.Addr())
+					return apperrors.NewUnauthorized("client certificate is required")
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// function to get the client certificate which was verified by the tls handshake, nil for requests without certificate
+func getClientCertificate(c echo.Context) *x509.Certificate {
+	state := c.Request().TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+// function to set authentication challenges of supported schemes
+func setAuthenticateHeaders(c echo.Context, bearerError string) {
+	header := c.Response().Header()
+	if len(bearerError) > 0 {
+		header.Add(echo.HeaderWWWAuthenticate, "Bearer "+bearerError)
+	} else {
+		header.Add(echo.HeaderWWWAuthenticate, "Bearer")
+	}
+	header.Add(echo.HeaderWWWAuthenticate, "ApiKey")
+}
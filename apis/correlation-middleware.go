@@ -0,0 +1,54 @@
+package apis
+
+import (
+	"GolangCourse/commons/apploggers"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const headerTraceparent = "traceparent"
+
+var (
+	// ids of callers are logged, so only short ids of safe characters are accepted
+	correlationIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+	// W3C trace context, version-traceid-parentid-flags
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// correlation middleware, creates the logger of the request with the correlation id of the caller
+// id is taken from X-Correlation-ID, X-Request-ID or the trace id of traceparent, a new id is generated otherwise
+// the logger is stored in the echo context and the id is returned in the response headers
+func CorrelationMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			request := c.Request()
+			lcontext, _ := apploggers.NewLoggerWithCorrelationid(request.Context(), getCorrelationId(request.Header))
+			c.Set("context", lcontext)
+			c.SetRequest(request.WithContext(lcontext))
+
+			correlationId := apploggers.GetCorrelationId(lcontext)
+			c.Response().Header().Set(echo.HeaderXCorrelationID, correlationId)
+			c.Response().Header().Set(echo.HeaderXRequestID, correlationId)
+			return next(c)
+		}
+	}
+}
+
+// function to get correlation id of the caller, empty when the caller has not sent a valid id
+func getCorrelationId(header http.Header) string {
+	for _, name := range []string{echo.HeaderXCorrelationID, echo.HeaderXRequestID} {
+		if value := strings.TrimSpace(header.Get(name)); correlationIdPattern.MatchString(value) {
+			return value
+		}
+	}
+	if match := traceparentPattern.FindStringSubmatch(strings.TrimSpace(header.Get(headerTraceparent))); match != nil {
+		// all zero trace id is invalid
+		if strings.Trim(match[1], "0") != "" {
+			return match[1]
+		}
+	}
+	return ""
+}
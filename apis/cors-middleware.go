@@ -0,0 +1,23 @@
+package apis
+
+import (
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// cors middleware, allowed origins are read per request, so that they are changed on configuration reload
+// "*" allows all origins, requests of other origins are served without cors headers
+func CorsMiddleware(origins func() []string) echo.MiddlewareFunc {
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOriginFunc: func(origin string) (bool, error) {
+			allowed := origins()
+			return slices.Contains(allowed, "*") || slices.Contains(allowed, origin), nil
+		},
+		AllowHeaders: []string{echo.HeaderAuthorization, echo.HeaderContentType, echo.HeaderXCorrelationID, echo.HeaderXRequestID, headerTraceparent},
+		ExposeHeaders: []string{echo.HeaderXCorrelationID, echo.HeaderXRequestID, echo.HeaderRetryAfter,
+			"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+		MaxAge: 600,
+	})
+}
@@ -0,0 +1,83 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"GolangCourse/internals/services"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type dlcontroller struct {
+	dlservice services.DebugLogService
+}
+
+func NewDebugLogController(dlservice services.DebugLogService) dlcontroller {
+	return dlcontroller{
+		dlservice: dlservice,
+	}
+}
+
+// @Tags Log Management
+// @Summary GetRecentLogs
+// @Description Get recent log entries of the instance, oldest first, e.g. all entries of a request by its correlation id
+// @Produce json
+// @Param correlation_id query string false "Correlation id of the request"
+// @Param level query string false "Min level, debug, info, warn or error"
+// @Param since query string false "RFC 3339 time or duration before now, e.g. 15m"
+// @Param limit query int false "Max number of newest entries, default 500"
+// @Success 200 {array} models.LogEntry
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /debug/logs [get]
+func (d *dlcontroller) GetRecentLogs(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing GetRecentLogs")
+	query, qerror := getLogQuery(c)
+	if qerror != nil {
+		return apperrors.NewInvalidArgument(qerror.Error())
+	}
+	entries, serror := d.dlservice.GetRecentLogs(lcontext, query)
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed GetRecentLogs, entries: %d", len(entries))
+	return c.JSON(http.StatusOK, entries)
+}
+
+// function to get log query from query params
+func getLogQuery(c echo.Context) (*models.LogQuery, error) {
+	query := &models.LogQuery{
+		CorrelationId: strings.TrimSpace(c.QueryParam("correlation_id")),
+		Level:         strings.ToLower(strings.TrimSpace(c.QueryParam("level"))),
+	}
+	if value := strings.TrimSpace(c.QueryParam("since")); len(value) > 0 {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			duration, derr := time.ParseDuration(value)
+			if derr != nil {
+				return nil, fmt.Errorf("'since' must be a RFC 3339 time or a duration: %s", value)
+			}
+			since = time.Now().Add(-duration)
+		}
+		query.Since = &since
+	}
+	if value := c.QueryParam("limit"); len(value) > 0 {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("'limit' must be a number: %s", value)
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
@@ -0,0 +1,75 @@
+package apis
+
+import (
+	"GolangCourse/commons"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// http status codes of domain errors
+var errorStatusCodes = map[apperrors.ErrorKind]int{
+	apperrors.InvalidArgument:      http.StatusBadRequest,
+	apperrors.Unauthorized:         http.StatusUnauthorized,
+	apperrors.Forbidden:            http.StatusForbidden,
+	apperrors.NotFound:             http.StatusNotFound,
+	apperrors.Conflict:             http.StatusConflict,
+	apperrors.UnsupportedMediaType: http.StatusUnsupportedMediaType,
+	apperrors.PayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	apperrors.RateLimited:          http.StatusTooManyRequests,
+	apperrors.Unavailable:          http.StatusServiceUnavailable,
+	apperrors.Timeout:              http.StatusGatewayTimeout,
+	apperrors.Internal:             http.StatusInternalServerError,
+}
+
+// central error handler for echo, maps domain and echo errors to application/problem+json responses
+// causes of domain errors are only logged, the caller gets the message and the correlationid
+func HttpErrorHandler(err error, c echo.Context) {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	if c.Response().Committed {
+		logger.Errorf("request failed after response was committed, error: %v", err)
+		return
+	}
+
+	var problem *commons.ProblemDetails
+	var appError *apperrors.AppError
+	var httpError *echo.HTTPError
+	switch {
+	case errors.As(err, &appError):
+		status, ok := errorStatusCodes[appError.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		problem = commons.ProblemResponse(status, string(appError.Kind), appError.Message, appError.Details)
+	case errors.As(err, &httpError):
+		message := http.StatusText(httpError.Code)
+		if value, ok := httpError.Message.(string); ok {
+			message = value
+		}
+		problem = commons.ProblemResponse(httpError.Code, "", message, nil)
+	default:
+		problem = commons.ProblemResponse(http.StatusInternalServerError, string(apperrors.Internal), "internal server error", nil)
+	}
+	problem.Instance = c.Request().URL.Path
+	problem.CorrelationId = apploggers.GetCorrelationId(lcontext)
+
+	if problem.Status >= http.StatusInternalServerError {
+		logger.Errorf("request failed, status: %d, error: %v", problem.Status, err)
+	} else {
+		logger.Warnf("request rejected, status: %d, error: %v", problem.Status, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, commons.ProblemJsonContentType)
+	var rerror error
+	if c.Request().Method == http.MethodHead {
+		rerror = c.NoContent(problem.Status)
+	} else {
+		rerror = c.JSON(problem.Status, problem)
+	}
+	if rerror != nil {
+		logger.Error(rerror)
+	}
+}
@@ -0,0 +1,24 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/appfeatures"
+	"GolangCourse/commons/apploggers"
+
+	"github.com/labstack/echo/v4"
+)
+
+// feature middleware, routes of disabled features respond as not found
+// flags are checked per request, so that features are enabled and disabled on configuration reload
+func RequireFeature(name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !appfeatures.Enabled(name) {
+				_, logger := apploggers.GetLoggerFromEcho(c)
+				logger.Debugf("feature %s is disabled, route: %s", name, c.Path())
+				return apperrors.NewNotFound("route is not available")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// function to decode the json body of the request into target
+// unknown fields and data after the json value are rejected, so that typos and unexpected fields are not ignored
+func bindStrictJson(c echo.Context, target interface{}) error {
+	request := c.Request()
+	if contentType, _, _ := mime.ParseMediaType(request.Header.Get(echo.HeaderContentType)); contentType != echo.MIMEApplicationJSON {
+		return apperrors.New(apperrors.UnsupportedMediaType, "unsupported content type, use "+echo.MIMEApplicationJSON)
+	}
+	decoder := json.NewDecoder(request.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(target)
+	if err == nil {
+		// errors of reading the rest of the body are kept, e.g. the body exceeds the size limit after the json value
+		if rerror := decoder.Decode(&json.RawMessage{}); rerror == nil {
+			err = errors.New("unexpected data after json value")
+		} else if rerror != io.EOF {
+			err = rerror
+		}
+	}
+	var sizeError *http.MaxBytesError
+	switch {
+	case errors.As(err, &sizeError):
+		return payloadTooLarge(sizeError.Limit)
+	case errors.Is(err, io.EOF):
+		return apperrors.NewInvalidArgument("invalid request payload, body is empty")
+	case err != nil:
+		return apperrors.NewInvalidArgument("invalid request payload, " + err.Error())
+	}
+	return nil
+}
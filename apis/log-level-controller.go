@@ -0,0 +1,71 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"GolangCourse/internals/services"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type llcontroller struct {
+	llservice services.LogLevelService
+}
+
+func NewLogLevelController(llservice services.LogLevelService) llcontroller {
+	return llcontroller{
+		llservice: llservice,
+	}
+}
+
+// @Tags Log Management
+// @Summary GetLogLevels
+// @Description Get the current global and package log levels
+// @Produce json
+// @Success 200 {object} models.LogLevels
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /admin/log-levels [get]
+func (l *llcontroller) GetLogLevels(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing GetLogLevels")
+	levels := l.llservice.GetLogLevels(lcontext)
+	logger.Info("Executed GetLogLevels")
+	return c.JSON(http.StatusOK, levels)
+}
+
+// @Tags Log Management
+// @Summary SetLogLevels
+// @Description Change the global and package log levels of the instance, levels with ttl are reverted after the ttl
+// @Accept json
+// @Produce json
+// @Param payload body models.SetLogLevelsRequest true "Log levels"
+// @Success 200 {object} models.LogLevels
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /admin/log-levels [put]
+func (l *llcontroller) SetLogLevels(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing SetLogLevels")
+	var request *models.SetLogLevelsRequest
+	err := c.Bind(&request)
+	if err != nil || request == nil {
+		return apperrors.NewInvalidArgument("invalid request payload")
+	}
+	if verror := c.Validate(request); verror != nil {
+		return verror
+	}
+	levels, serror := l.llservice.SetLogLevels(lcontext, request)
+	if serror != nil {
+		return serror
+	}
+	logger.Info("Executed SetLogLevels")
+	return c.JSON(http.StatusOK, levels)
+}
@@ -0,0 +1,46 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/appmetrics"
+	"crypto/subtle"
+
+	"github.com/labstack/echo/v4"
+)
+
+// metrics middleware, records requests by route template instead of path, as paths can contain ids and emails
+// requests without route are recorded as "unmatched", so that scans of unknown paths do not create new series
+func MetricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			finish := appmetrics.StartHttpRequest()
+			// errors are handled here, so that the status of the error response is recorded
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			route := c.Path()
+			if len(route) == 0 {
+				route = "unmatched"
+			}
+			finish(c.Request().Method, route, c.Response().Status)
+			return nil
+		}
+	}
+}
+
+// middleware of the metrics endpoint, scrapers must send the token as bearer token when the token is configured
+func MetricsTokenMiddleware(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(token) == 0 {
+				return next(c)
+			}
+			expected := "Bearer " + token
+			if subtle.ConstantTimeCompare([]byte(c.Request().Header.Get(echo.HeaderAuthorization)), []byte(expected)) != 1 {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="metrics"`)
+				return apperrors.NewUnauthorized("invalid metrics token")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"GolangCourse/internals/services"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type mcontroller struct {
+	mservice services.MfaService
+}
+
+func NewMfaController(mservice services.MfaService) mcontroller {
+	return mcontroller{
+		mservice: mservice,
+	}
+}
+
+// @Tags User Management
+// @Summary EnrollTotp
+// @Description Start TOTP enrollment of own user, returns secret and otpauth uri for QR code
+// @Produce json
+// @Param id path string true "User id"
+// @Success 200 {object} models.TotpEnrollment
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 409 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /users/{id}/mfa/totp [post]
+func (m *mcontroller) EnrollTotp(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	userId := c.Param("id")
+	logger.Infof("Executing EnrollTotp, userId: %s", userId)
+	if len(strings.TrimSpace(userId)) == 0 {
+		return apperrors.NewInvalidArgument("'id' is required")
+	}
+	enrollment, serror := m.mservice.EnrollTotp(lcontext, userId)
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed EnrollTotp, userId: %s", userId)
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// @Tags User Management
+// @Summary VerifyTotp
+// @Description Verify TOTP code of the enrollment and enable two-factor authentication, returns recovery codes once
+// @Accept json
+// @Produce json
+// @Param id path string true "User id"
+// @Param payload body models.TotpVerifyRequest true "TOTP code"
+// @Success 200 {object} models.RecoveryCodes
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 409 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /users/{id}/mfa/totp/verify [post]
+func (m *mcontroller) VerifyTotp(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	userId := c.Param("id")
+	logger.Infof("Executing VerifyTotp, userId: %s", userId)
+	if len(strings.TrimSpace(userId)) == 0 {
+		return apperrors.NewInvalidArgument("'id' is required")
+	}
+	var request *models.TotpVerifyRequest
+	err := c.Bind(&request)
+	if err != nil || request == nil {
+		return apperrors.NewInvalidArgument("invalid request payload")
+	}
+	if verror := c.Validate(request); verror != nil {
+		return verror
+	}
+	codes, serror := m.mservice.VerifyTotp(lcontext, userId, request.Code)
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed VerifyTotp, userId: %s", userId)
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusOK, codes)
+}
+
+// @Tags User Management
+// @Summary ResetMfa
+// @Description Reset two-factor authentication of user, e.g. when the device is lost
+// @Param id path string true "User id"
+// @Success 204
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Router /users/{id}/mfa [delete]
+func (m *mcontroller) ResetMfa(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	userId := c.Param("id")
+	logger.Infof("Executing ResetMfa, userId: %s", userId)
+	if len(strings.TrimSpace(userId)) == 0 {
+		return apperrors.NewInvalidArgument("'id' is required")
+	}
+	if serror := m.mservice.ResetMfa(lcontext, userId); serror != nil {
+		return serror
+	}
+	logger.Infof("Executed ResetMfa, userId: %s", userId)
+	return c.NoContent(http.StatusNoContent)
+}
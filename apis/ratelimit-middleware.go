@@ -0,0 +1,75 @@
+package apis
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/commons/appratelimit"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// rate limit middleware, requests are limited per client and route
+// clients are identified by api key or user of the request, anonymous requests by client ip
+// so the middleware must be used after the authentication middleware
+// limits are read per request, so that they are changed on configuration reload
+func RateLimitMiddleware(limiter *appratelimit.Limiter, config func() *appratelimit.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			name, rule := config().RuleFor(c.Request().Method, c.Path())
+			if rule == nil {
+				return next(c)
+			}
+			client := "ip:" + c.RealIP()
+			if principal := appauth.GetPrincipal(c.Request().Context()); principal != nil {
+				client = principal.String()
+			}
+			if lerror := allowRequest(c, limiter, name, client, rule); lerror != nil {
+				return lerror
+			}
+			return next(c)
+		}
+	}
+}
+
+// client ip rate limit middleware, all requests of a client ip are limited by one rule
+// it must be used before the authentication middleware, so that requests with invalid credentials are limited
+func ClientIpRateLimitMiddleware(limiter *appratelimit.Limiter, config func() *appratelimit.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rule := config().ClientIp
+			if rule == nil {
+				return next(c)
+			}
+			if lerror := allowRequest(c, limiter, "client-ip", "ip:"+c.RealIP(), rule); lerror != nil {
+				return lerror
+			}
+			return next(c)
+		}
+	}
+}
+
+// function to take a token of the bucket of the client, headers are set from the result
+func allowRequest(c echo.Context, limiter *appratelimit.Limiter, name string, client string, rule *appratelimit.Rule) error {
+	result := limiter.Allow(name+"|"+client, rule)
+
+	header := c.Response().Header()
+	header.Set("RateLimit-Policy", rule.Policy())
+	header.Set("RateLimit-Limit", strconv.Itoa(rule.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.Reset), 10))
+	if !result.Allowed {
+		_, logger := apploggers.GetLoggerFromEcho(c)
+		logger.Warnf("rate limit exceeded, client: %s, route: %s, policy: %s", client, name, rule.Policy())
+		header.Set(echo.HeaderRetryAfter, strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+		return apperrors.New(apperrors.RateLimited, "rate limit exceeded, retry after "+strconv.FormatInt(ceilSeconds(result.RetryAfter), 10)+" seconds")
+	}
+	return nil
+}
+
+func ceilSeconds(duration time.Duration) int64 {
+	return int64(math.Ceil(duration.Seconds()))
+}
@@ -0,0 +1,35 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// recover middleware, panics of handlers are logged with the stack and the correlation id of the request
+// and returned as internal error, so that the connection is not dropped and the request is logged
+func RecoverMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// ErrAbortHandler aborts the response on purpose, it is handled by the server
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				_, logger := apploggers.GetLoggerFromEcho(c)
+				logger.With(zap.String("stack", string(debug.Stack()))).Errorf("panic recovered, route: %s, panic: %v", c.Path(), recovered)
+				err = apperrors.NewInternal("internal server error", fmt.Errorf("panic: %v", recovered))
+			}()
+			return next(c)
+		}
+	}
+}
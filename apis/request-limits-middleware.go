@@ -0,0 +1,85 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// time to write the error response of requests which exceeded their deadline
+const deadlineGrace = 5 * time.Second
+
+// body limit middleware, requests with larger bodies are rejected with 413
+// limits of routes are keyed by method and route template, e.g. "POST /users/import"
+func BodyLimitMiddleware(limit int64, routes map[string]int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			request := c.Request()
+			routeLimit, ok := routes[routeKey(request.Method, c.Path())]
+			if !ok {
+				routeLimit = limit
+			}
+			if routeLimit <= 0 {
+				return next(c)
+			}
+			if request.ContentLength > routeLimit {
+				_, logger := apploggers.GetLoggerFromEcho(c)
+				logger.Warnf("request body too large, size: %d, limit: %d", request.ContentLength, routeLimit)
+				return payloadTooLarge(routeLimit)
+			}
+			// bodies without content length are limited while they are read
+			request.Body = http.MaxBytesReader(c.Response(), request.Body, routeLimit)
+			return next(c)
+		}
+	}
+}
+
+// deadline middleware, the context of the request is cancelled after the timeout of the route
+// the deadline is propagated to services and db operations through the request context
+// read and write deadlines of the connection are moved, so that routes with long timeouts are not cut by the server timeouts
+func DeadlineMiddleware(timeout time.Duration, routes map[string]time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			routeTimeout, ok := routes[routeKey(c.Request().Method, c.Path())]
+			if !ok {
+				routeTimeout = timeout
+			}
+			if routeTimeout <= 0 {
+				return next(c)
+			}
+			controller := http.NewResponseController(c.Response())
+			_ = controller.SetReadDeadline(time.Now().Add(routeTimeout))
+			_ = controller.SetWriteDeadline(time.Now().Add(routeTimeout + deadlineGrace))
+
+			lcontext, _ := apploggers.GetLoggerFromEcho(c)
+			lcontext, cancel := context.WithTimeout(lcontext, routeTimeout)
+			defer cancel()
+			c.Set("context", lcontext)
+			c.SetRequest(c.Request().WithContext(lcontext))
+
+			err := next(c)
+			if err != nil && errors.Is(lcontext.Err(), context.DeadlineExceeded) {
+				_, logger := apploggers.GetLoggerFromEcho(c)
+				logger.Warnf("request deadline exceeded, timeout: %s, error: %v", routeTimeout, err)
+				return apperrors.Wrap(apperrors.Timeout, fmt.Sprintf("request timed out after %s", routeTimeout), err)
+			}
+			return err
+		}
+	}
+}
+
+// function to get the error of bodies over the limit
+func payloadTooLarge(limit int64) error {
+	return apperrors.New(apperrors.PayloadTooLarge, fmt.Sprintf("request body is larger than %d bytes", limit))
+}
+
+func routeKey(method string, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
@@ -0,0 +1,58 @@
+package apis
+
+import (
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/commons/apptracing"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// tracing middleware, starts the server span of the request as child of the W3C trace context of the caller
+// trace and span id are added to the logger of the request, so that logs and traces of a request are linked
+// the span is named by the route template instead of the path, as paths can contain emails
+func TracingMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			request := c.Request()
+			lcontext, _ := apploggers.GetLoggerFromEcho(c)
+			lcontext = otel.GetTextMapPropagator().Extract(lcontext, propagation.HeaderCarrier(request.Header))
+			route := c.Path()
+			if len(route) == 0 {
+				route = "unmatched"
+			}
+			lcontext, span := apptracing.Start(lcontext, request.Method+" "+route, trace.SpanKindServer,
+				semconv.HTTPRequestMethodKey.String(request.Method),
+				semconv.HTTPRoute(route),
+			)
+			defer span.End()
+			if spanContext := span.SpanContext(); spanContext.IsValid() {
+				lcontext, _ = apploggers.WithLoggerFields(lcontext,
+					zap.String("trace_id", spanContext.TraceID().String()),
+					zap.String("span_id", spanContext.SpanID().String()),
+				)
+			}
+			c.Set("context", lcontext)
+			c.SetRequest(request.WithContext(lcontext))
+
+			// errors are handled here, so that the status of the error response is recorded
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			// client errors are not errors of the server
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, fmt.Sprintf("http status %d", status))
+			}
+			return nil
+		}
+	}
+}
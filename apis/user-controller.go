@@ -1,11 +1,19 @@
 package apis
 
 import (
-	"GolangCourse/commons"
+	"GolangCourse/commons/apperrors"
 	"GolangCourse/commons/apploggers"
 	"GolangCourse/internals/models"
 	"GolangCourse/internals/services"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -27,23 +35,75 @@ func NewUserController(eservice services.EventService) ucontroller {
 // @Accept json
 // @Produce json
 // @Param id path string true "User id"
+// @Param fields query string false "Comma separated fields e.g. name,email, '_id' is always returned"
 // @Success 200 {object} models.User
-// @Failure 400 {object} commons.ApiErrorResponsePayload
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /users/{id} [Get]
 func (u *ucontroller) GetUserById(c echo.Context) error {
 	lcontext, logger := apploggers.GetLoggerFromEcho(c)
 	userId := c.Param("id")
 	logger.Infof("Executing GetUserById, userId: %s", userId)
 	if len(strings.TrimSpace(userId)) == 0 {
-		logger.Error("'id' is required")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("'id' is required", nil))
+		return apperrors.NewInvalidArgument("'id' is required")
 	}
-	user, serror := u.eservice.GetUserById(lcontext, userId)
+	fields, ferror := getSelectedUserFields(c)
+	if ferror != nil {
+		return apperrors.NewInvalidArgument(ferror.Error())
+	}
+	user, serror := u.eservice.GetUserById(lcontext, userId, fields)
 	if serror != nil {
-		logger.Error(serror)
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse(serror.Error(), nil))
+		return serror
+	}
+	logger.Infof("Executed GetUserById, userId: %s", userId)
+	if len(fields) > 0 {
+		return c.JSON(http.StatusOK, selectUserFields(user, fields))
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// @Tags User Management
+// @Summary GetUserByEmail
+// @Description Gets user details by email, emails are matched case insensitively
+// @Accept json
+// @Produce json
+// @Param email path string true "User email"
+// @Param fields query string false "Comma separated fields e.g. name,email, '_id' is always returned"
+// @Success 200 {object} models.User
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /users/by-email/{email} [Get]
+func (u *ucontroller) GetUserByEmail(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing GetUserByEmail")
+	email, perror := url.PathUnescape(c.Param("email"))
+	if perror != nil || len(strings.TrimSpace(email)) == 0 {
+		return apperrors.NewInvalidArgument("'email' is required")
+	}
+	fields, ferror := getSelectedUserFields(c)
+	if ferror != nil {
+		return apperrors.NewInvalidArgument(ferror.Error())
+	}
+	user, serror := u.eservice.GetUserByEmail(lcontext, email, fields)
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed GetUserByEmail, userId: %s", user.Id.Hex())
+	if len(fields) > 0 {
+		return c.JSON(http.StatusOK, selectUserFields(user, fields))
 	}
-	logger.Infof("Executed GetUserById, userId:%s, user %s", userId, commons.PrintStruct(user))
 	return c.JSON(http.StatusOK, user)
 }
 
@@ -54,20 +114,25 @@ func (u *ucontroller) GetUserById(c echo.Context) error {
 // @Produce json
 // @Param id path string true "User id"
 // @Success 204
-// @Failure 400 {object} commons.ApiErrorResponsePayload
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /users/{id} [Delete]
 func (u *ucontroller) DeleteUserById(c echo.Context) error {
 	lcontext, logger := apploggers.GetLoggerFromEcho(c)
 	userId := c.Param("id")
 	logger.Infof("Executing DeleteUserById, userId: %s", userId)
 	if len(strings.TrimSpace(userId)) == 0 {
-		logger.Error("'id' is required")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("'id' is required", nil))
+		return apperrors.NewInvalidArgument("'id' is required")
 	}
 	serror := u.eservice.DeleteUserById(lcontext, userId)
 	if serror != nil {
-		logger.Error(serror)
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse(serror.Error(), nil))
+		return serror
 	}
 	logger.Infof("Executed DeleteUserById, userId: %s", userId)
 	return c.NoContent(http.StatusNoContent)
@@ -75,21 +140,51 @@ func (u *ucontroller) DeleteUserById(c echo.Context) error {
 
 // @Tags User Management
 // @Summary GetUsers
-// @Description get details of all users
+// @Description get details of all users matching the filters
 // @Accept json
 // @Produce json
+// @Param name query string false "Name contains, case insensitive"
+// @Param email query string false "Email"
+// @Param type query string false "User type"
+// @Param is_active query bool false "Active status"
+// @Param min_age query int false "Minimum age"
+// @Param max_age query int false "Maximum age"
+// @Param fields query string false "Comma separated fields e.g. name,email, '_id' is always returned"
 // @Success 200 {object} []models.User
-// @Failure 400 {object} commons.ApiErrorResponsePayload
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /users [Get]
 func (u *ucontroller) GetUsers(c echo.Context) error {
 	lcontext, logger := apploggers.GetLoggerFromEcho(c)
 	logger.Info("Executing Get All Users")
-	users, serror := u.eservice.GetUsers(lcontext)
+	filter, ferror := getUserFilter(c)
+	if ferror != nil {
+		return apperrors.NewInvalidArgument(ferror.Error())
+	}
+	fields, ferror := getSelectedUserFields(c)
+	if ferror != nil {
+		return apperrors.NewInvalidArgument(ferror.Error())
+	}
+	users, serror := u.eservice.GetUsers(lcontext, filter, fields)
 	if serror != nil {
-		logger.Error(serror)
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse(serror.Error(), nil))
+		return serror
+	}
+	logger.Infof("Executed GetUsers, users: %d", len(users))
+	if len(fields) > 0 {
+		selected := make([]map[string]interface{}, 0, len(users))
+		for _, user := range users {
+			selected = append(selected, selectUserFields(user, fields))
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"total": len(selected),
+			"users": selected,
+		})
 	}
-	logger.Infof("Executed GetUsers, users %s", commons.PrintStruct(users))
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"total": len(users),
 		"users": users,
@@ -101,33 +196,33 @@ func (u *ucontroller) GetUsers(c echo.Context) error {
 // @Description Create a user with name, email, age, and is_Active status
 // @Accept json
 // @Produce json
-// @Param payload body models.User true "User data"
+// @Param payload body models.UserRequest true "User data, unknown fields are rejected"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} commons.ApiErrorResponsePayload
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 409 {object} commons.ProblemDetails
+// @Failure 413 {object} commons.ProblemDetails
+// @Failure 415 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /users [post]
 func (u *ucontroller) CreateUser(c echo.Context) error {
 	lcontext, logger := apploggers.GetLoggerFromEcho(c)
 	logger.Info("Executing CreateUser")
-	var user *models.User
-	err := c.Bind(&user)
-	if err != nil || user == nil {
-		logger.Error("invalid request payload")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("invalid request payload", nil))
-	}
-
-	if len(strings.TrimSpace(user.Name)) == 0 {
-		logger.Error("'name' is required")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("'name' is required", nil))
+	var request models.UserRequest
+	if berror := bindStrictJson(c, &request); berror != nil {
+		return berror
 	}
 
-	if len(strings.TrimSpace(user.Email)) == 0 {
-		logger.Error("'email' is required")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("'email' is required", nil))
+	if verror := c.Validate(&request); verror != nil {
+		return verror
 	}
-	Id, serror := u.eservice.CreateUser(lcontext, user)
+	Id, serror := u.eservice.CreateUser(lcontext, request.User())
 	if serror != nil {
-		logger.Error(serror)
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse(serror.Error(), nil))
+		return serror
 	}
 	logger.Info("Executed CreateUser")
 	return c.JSON(http.StatusCreated, map[string]string{
@@ -136,44 +231,175 @@ func (u *ucontroller) CreateUser(c echo.Context) error {
 }
 
 // @Tags User Management
-// @Summary UpdateUser
-// @Description update user details such as name, email, age, and is_Active status bu user id
+// @Summary ReplaceUser
+// @Description replace user details such as name, email, age, and is_Active status by user id, fields not provided are reset
 // @Accept json
 // @Produce json
-// @Param payload body models.User true "User data"
+// @Param payload body models.UserRequest true "User data, unknown fields are rejected"
 // @Param id path string true "User Id"
 // @Success 200
-// @Failure 400 {object} commons.ApiErrorResponsePayload
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 409 {object} commons.ProblemDetails
+// @Failure 413 {object} commons.ProblemDetails
+// @Failure 415 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /users/{id} [put]
+func (u *ucontroller) ReplaceUser(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	userId := c.Param("id")
+	logger.Infof("Executing ReplaceUser, userId: %s", userId)
+	if len(strings.TrimSpace(userId)) == 0 {
+		return apperrors.NewInvalidArgument("'id' is required")
+	}
+	var request models.UserRequest
+	if berror := bindStrictJson(c, &request); berror != nil {
+		return berror
+	}
+
+	if verror := c.Validate(&request); verror != nil {
+		return verror
+	}
+	serror := u.eservice.UpdateUser(lcontext, request.User(), userId)
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed ReplaceUser, userId: %s", userId)
+	return c.NoContent(http.StatusOK)
+}
+
+// @Tags User Management
+// @Summary UpdateUser
+// @Description partially update user by user id, only the supplied fields are updated.
+// @Description Accepts json merge patch (application/merge-patch+json, also used for application/json) or json patch (application/json-patch+json)
+// @Accept json,application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param payload body object true "Merge patch document or array of json patch operations"
+// @Param id path string true "User Id"
+// @Success 200 {object} models.User
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 404 {object} commons.ProblemDetails
+// @Failure 409 {object} commons.ProblemDetails
+// @Failure 415 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /users/{id} [patch]
 func (u *ucontroller) UpdateUser(c echo.Context) error {
 	lcontext, logger := apploggers.GetLoggerFromEcho(c)
 	userId := c.Param("id")
-	logger.Info("Executing UpdateUser, userId: %s", userId)
+	logger.Infof("Executing UpdateUser, userId: %s", userId)
 	if len(strings.TrimSpace(userId)) == 0 {
-		logger.Error("'id' is required")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("'id' is required", nil))
+		return apperrors.NewInvalidArgument("'id' is required")
 	}
-	var user *models.User
-	err := c.Bind(&user)
-	if err != nil || user == nil {
-		logger.Error("invalid request payload")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("invalid request payload", nil))
+
+	contentType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch contentType {
+	case models.MergePatchContentType, models.JsonPatchContentType:
+	case echo.MIMEApplicationJSON, "":
+		// plain json payloads are treated as merge patch
+		contentType = models.MergePatchContentType
+	default:
+		return apperrors.New(apperrors.UnsupportedMediaType, "unsupported content type, use "+
+			models.MergePatchContentType+" or "+models.JsonPatchContentType)
+	}
+	patch, err := io.ReadAll(c.Request().Body)
+	var sizeError *http.MaxBytesError
+	if errors.As(err, &sizeError) {
+		return payloadTooLarge(sizeError.Limit)
+	}
+	if err != nil || len(bytes.TrimSpace(patch)) == 0 {
+		return apperrors.NewInvalidArgument("invalid request payload")
 	}
 
-	if len(strings.TrimSpace(user.Name)) == 0 {
-		logger.Error("'name' is required")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("'name' is required", nil))
+	user, serror := u.eservice.PatchUser(lcontext, userId, contentType, patch)
+	if serror != nil {
+		return serror
 	}
+	logger.Infof("Executed UpdateUser, userId: %s", userId)
+	return c.JSON(http.StatusOK, user)
+}
 
-	if len(strings.TrimSpace(user.Email)) == 0 {
-		logger.Error("'email' is required")
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse("'email' is required", nil))
+// function to get users filter from query params
+func getUserFilter(c echo.Context) (*models.UserFilter, error) {
+	filter := &models.UserFilter{
+		Name:  strings.TrimSpace(c.QueryParam("name")),
+		Email: models.NormalizeEmail(c.QueryParam("email")),
+		Type:  strings.TrimSpace(c.QueryParam("type")),
 	}
-	serror := u.eservice.UpdateUser(lcontext, user, userId)
-	if serror != nil {
-		logger.Error(serror)
-		return c.JSON(http.StatusBadRequest, commons.ApiErrorResponse(serror.Error(), nil))
+	if value := c.QueryParam("is_active"); len(value) > 0 {
+		isActive, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("'is_active' must be a boolean: %s", value)
+		}
+		filter.IsActive = &isActive
 	}
-	logger.Info("Executed UpdateUser, userId: %s", userId)
-	return c.NoContent(http.StatusOK)
+	for param, target := range map[string]**int{"min_age": &filter.MinAge, "max_age": &filter.MaxAge} {
+		if value := c.QueryParam(param); len(value) > 0 {
+			age, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' must be a number: %s", param, value)
+			}
+			*target = &age
+		}
+	}
+	return filter, nil
+}
+
+// function to get selected user fields from comma separated list, all fields are selected when empty
+func getUserFields(value string) ([]string, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return models.UserFields, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		if !slices.Contains(models.UserFields, field) {
+			return nil, fmt.Errorf("unknown field '%s', allowed fields: %s", field, strings.Join(models.UserFields, ","))
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return models.UserFields, nil
+	}
+	return fields, nil
+}
+
+// function to get sparse fieldset requested with 'fields' query param, '_id' is always included
+// nil fields are returned when the param is not provided, so that complete users are returned
+func getSelectedUserFields(c echo.Context) ([]string, error) {
+	value := c.QueryParam("fields")
+	if len(strings.TrimSpace(value)) == 0 {
+		return nil, nil
+	}
+	fields, err := getUserFields(value)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(fields, "_id") {
+		fields = append([]string{"_id"}, fields...)
+	}
+	return fields, nil
+}
+
+// function to get user document with selected fields only
+func selectUserFields(user *models.User, fields []string) map[string]interface{} {
+	document := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		document[field] = user.FieldValue(field)
+	}
+	return document
 }
@@ -0,0 +1,189 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	exportFormatCsv    = "csv"
+	exportFormatNdjson = "ndjson"
+	exportFormatJson   = "json"
+
+	// number of users written between flushes of the response
+	exportFlushInterval = 100
+)
+
+// @Tags User Management
+// @Summary ExportUsers
+// @Description Stream users matching the filters as csv, ndjson or json, with selected fields
+// @Produce json,text/csv,application/x-ndjson
+// @Param format query string false "csv, ndjson or json, default json"
+// @Param fields query string false "Comma separated fields e.g. name,email, default all fields"
+// @Param name query string false "Name contains, case insensitive"
+// @Param email query string false "Email"
+// @Param type query string false "User type"
+// @Param is_active query bool false "Active status"
+// @Param min_age query int false "Minimum age"
+// @Param max_age query int false "Maximum age"
+// @Success 200 {object} []models.User
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /users/export [Get]
+func (u *ucontroller) ExportUsers(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing ExportUsers")
+	format := strings.ToLower(c.QueryParam("format"))
+	if len(format) == 0 {
+		format = exportFormatJson
+	}
+	if format != exportFormatCsv && format != exportFormatNdjson && format != exportFormatJson {
+		return apperrors.NewInvalidArgument("'format' must be csv, ndjson or json")
+	}
+	fields, ferror := getUserFields(c.QueryParam("fields"))
+	if ferror != nil {
+		return apperrors.NewInvalidArgument(ferror.Error())
+	}
+	filter, ferror := getUserFilter(c)
+	if ferror != nil {
+		return apperrors.NewInvalidArgument(ferror.Error())
+	}
+
+	writer := newUserExportWriter(c.Response(), format, fields)
+	serror := u.eservice.ExportUsers(lcontext, filter, fields, writer.write)
+	if serror == nil {
+		serror = writer.end()
+	}
+	if serror != nil && writer.started {
+		// response is already streaming with status 200, the connection is aborted so that clients see the failed export
+		logger.Errorf("export failed after %d users, aborting response: %v", writer.count, serror)
+		panic(http.ErrAbortHandler)
+	}
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed ExportUsers, format: %s, users: %d", format, writer.count)
+	return nil
+}
+
+// writer to stream users in export format, headers are written with the first user
+// so that errors before any user is exported can still be returned as error response
+type userExportWriter struct {
+	response  *echo.Response
+	format    string
+	fields    []string
+	csvWriter *csv.Writer
+	started   bool
+	count     int
+}
+
+func newUserExportWriter(response *echo.Response, format string, fields []string) *userExportWriter {
+	return &userExportWriter{
+		response: response,
+		format:   format,
+		fields:   fields,
+	}
+}
+
+// function to write response headers and format preamble
+func (w *userExportWriter) begin() error {
+	w.started = true
+	header := w.response.Header()
+	switch w.format {
+	case exportFormatCsv:
+		header.Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		header.Set(echo.HeaderContentDisposition, `attachment; filename="users.csv"`)
+	case exportFormatNdjson:
+		header.Set(echo.HeaderContentType, "application/x-ndjson")
+		header.Set(echo.HeaderContentDisposition, `attachment; filename="users.ndjson"`)
+	default:
+		header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	}
+	w.response.WriteHeader(http.StatusOK)
+
+	switch w.format {
+	case exportFormatCsv:
+		w.csvWriter = csv.NewWriter(w.response)
+		return w.csvWriter.Write(w.fields)
+	case exportFormatJson:
+		_, err := w.response.Write([]byte("["))
+		return err
+	}
+	return nil
+}
+
+func (w *userExportWriter) write(user *models.User) error {
+	if !w.started {
+		if err := w.begin(); err != nil {
+			return err
+		}
+	}
+	if w.format == exportFormatCsv {
+		record := make([]string, 0, len(w.fields))
+		for _, field := range w.fields {
+			record = append(record, fmt.Sprint(user.FieldValue(field)))
+		}
+		if err := w.csvWriter.Write(record); err != nil {
+			return err
+		}
+	} else {
+		payload, err := json.Marshal(selectUserFields(user, w.fields))
+		if err != nil {
+			return err
+		}
+		if w.format == exportFormatJson && w.count > 0 {
+			payload = append([]byte(","), payload...)
+		}
+		if w.format == exportFormatNdjson {
+			payload = append(payload, '\n')
+		}
+		if _, err := w.response.Write(payload); err != nil {
+			return err
+		}
+	}
+
+	// send exported users to the client in chunks instead of buffering the whole export
+	w.count++
+	if w.count%exportFlushInterval == 0 {
+		if w.csvWriter != nil {
+			w.csvWriter.Flush()
+		}
+		w.response.Flush()
+	}
+	return nil
+}
+
+// function to complete the export, empty exports still write headers and preamble
+func (w *userExportWriter) end() error {
+	if !w.started {
+		if err := w.begin(); err != nil {
+			return err
+		}
+	}
+	if w.format == exportFormatCsv {
+		w.csvWriter.Flush()
+		if err := w.csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+	if w.format == exportFormatJson {
+		if _, err := w.response.Write([]byte("]")); err != nil {
+			return err
+		}
+	}
+	w.response.Flush()
+	return nil
+}
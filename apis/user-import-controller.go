@@ -0,0 +1,134 @@
+package apis
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"GolangCourse/internals/services"
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type icontroller struct {
+	iservice services.ImportService
+}
+
+func NewUserImportController(iservice services.ImportService) icontroller {
+	return icontroller{
+		iservice: iservice,
+	}
+}
+
+// @Tags User Management
+// @Summary ImportUsers
+// @Description Import users from csv or ndjson file, rows are upserted by email. Columns are mapped to user fields by header name or by 'mapping' e.g. "Full Name:name,E-mail:email"
+// @Accept multipart/form-data,text/csv,application/x-ndjson
+// @Produce json,text/csv
+// @Param file formData file false "CSV or NDJSON file, when not sent as request body"
+// @Param format query string false "csv or ndjson, detected from content type or file name when not provided"
+// @Param mapping query string false "Mapping of source columns to user fields"
+// @Param batch_size query int false "Number of users upserted per batch"
+// @Param report query string false "Set to 'csv' to download the rejected rows report"
+// @Success 200 {object} models.ImportSummary
+// @Failure 400 {object} commons.ProblemDetails
+// @Failure 503 {object} commons.ProblemDetails
+// @Failure 401 {object} commons.ProblemDetails
+// @Failure 403 {object} commons.ProblemDetails
+// @Failure 429 {object} commons.ProblemDetails
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /users/import [post]
+func (i *icontroller) ImportUsers(c echo.Context) error {
+	lcontext, logger := apploggers.GetLoggerFromEcho(c)
+	logger.Info("Executing ImportUsers")
+	options := &models.ImportOptions{Format: strings.ToLower(strings.TrimSpace(c.QueryParam("format")))}
+	if mapping := c.QueryParam("mapping"); len(strings.TrimSpace(mapping)) > 0 {
+		parsed, perror := services.ParseImportMapping(mapping)
+		if perror != nil {
+			return perror
+		}
+		options.Mapping = parsed
+	}
+	if batchSize := c.QueryParam("batch_size"); len(batchSize) > 0 {
+		parsed, perror := strconv.Atoi(batchSize)
+		if perror != nil || parsed <= 0 {
+			return apperrors.NewInvalidArgument("'batch_size' must be a positive number")
+		}
+		options.BatchSize = parsed
+	}
+
+	// file is either uploaded as multipart form or streamed as request body
+	var source io.Reader = c.Request().Body
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+		fileHeader, ferror := c.FormFile("file")
+		if ferror != nil {
+			return apperrors.NewInvalidArgument("'file' is required")
+		}
+		file, ferror := fileHeader.Open()
+		if ferror != nil {
+			return apperrors.NewInvalidArgument("cannot read uploaded file")
+		}
+		defer file.Close()
+		source = file
+		contentType = fileHeader.Header.Get(echo.HeaderContentType)
+		if len(options.Format) == 0 {
+			options.Format = importFormatFromName(fileHeader.Filename)
+		}
+	}
+	if len(options.Format) == 0 {
+		options.Format = importFormatFromContentType(contentType)
+	}
+	if options.Format != models.ImportFormatCsv && options.Format != models.ImportFormatNdjson {
+		return apperrors.NewInvalidArgument("'format' must be csv or ndjson")
+	}
+
+	summary, serror := i.iservice.ImportUsers(lcontext, source, options)
+	if serror != nil {
+		return serror
+	}
+	logger.Infof("Executed ImportUsers, total: %d, rejected: %d", summary.Total, summary.Rejected)
+
+	if c.QueryParam("report") == models.ImportFormatCsv {
+		var report bytes.Buffer
+		if rerror := services.WriteImportReport(&report, summary); rerror != nil {
+			return apperrors.NewInternal("cannot write import report", rerror)
+		}
+		header := c.Response().Header()
+		header.Set(echo.HeaderContentDisposition, `attachment; filename="import-report.csv"`)
+		header.Set("X-Import-Total", strconv.Itoa(summary.Total))
+		header.Set("X-Import-Inserted", strconv.FormatInt(summary.Inserted, 10))
+		header.Set("X-Import-Updated", strconv.FormatInt(summary.Updated, 10))
+		header.Set("X-Import-Rejected", strconv.Itoa(summary.Rejected))
+		return c.Blob(http.StatusOK, "text/csv", report.Bytes())
+	}
+	return c.JSON(http.StatusOK, summary)
+}
+
+// function to detect import format from file name
+func importFormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return models.ImportFormatCsv
+	case ".ndjson", ".jsonl":
+		return models.ImportFormatNdjson
+	}
+	return ""
+}
+
+// function to detect import format from content type
+func importFormatFromContentType(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return models.ImportFormatCsv
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+		return models.ImportFormatNdjson
+	}
+	return ""
+}
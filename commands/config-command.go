@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"GolangCourse/configs"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// function to run the config command, e.g.
+// go run . --profile prod config print
+func RunConfig(config *configs.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing config command, expected print")
+	}
+	switch args[0] {
+	case "print":
+		return config.Print(os.Stdout)
+	default:
+		return fmt.Errorf("unknown config command: %s, expected print", args[0])
+	}
+}
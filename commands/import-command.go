@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"GolangCourse/internals/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// function to run the import command, e.g.
+// go run . import --file users.csv --mapping "Full Name:name,E-mail:email" --report rejected.csv
+func RunImport(ctx context.Context, iservice services.ImportService, args []string) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := flags.String("file", "", "csv or ndjson file with users to import (required)")
+	format := flags.String("format", "", "csv or ndjson, detected from file extension when not provided")
+	mapping := flags.String("mapping", "", "mapping of source columns to user fields, e.g. \"Full Name:name,E-mail:email\"")
+	batchSize := flags.Int("batch-size", 0, "number of users upserted per batch")
+	report := flags.String("report", "", "path of the rejected rows report, defaults to <file>.rejected.csv")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(*file)) == 0 {
+		flags.Usage()
+		return errors.New("'file' is required")
+	}
+
+	options := &models.ImportOptions{Format: strings.ToLower(*format), BatchSize: *batchSize}
+	if len(options.Format) == 0 {
+		switch strings.ToLower(filepath.Ext(*file)) {
+		case ".csv":
+			options.Format = models.ImportFormatCsv
+		case ".ndjson", ".jsonl":
+			options.Format = models.ImportFormatNdjson
+		default:
+			return fmt.Errorf("cannot detect format of %s, use --format csv|ndjson", *file)
+		}
+	}
+	if len(strings.TrimSpace(*mapping)) > 0 {
+		parsed, err := services.ParseImportMapping(*mapping)
+		if err != nil {
+			return err
+		}
+		options.Mapping = parsed
+	}
+
+	source, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	summary, err := iservice.ImportUsers(ctx, source, options)
+	if err != nil {
+		return err
+	}
+
+	reportPath := *report
+	if len(reportPath) == 0 {
+		reportPath = strings.TrimSuffix(*file, filepath.Ext(*file)) + ".rejected.csv"
+	}
+	if summary.Rejected > 0 {
+		reportFile, err := os.Create(reportPath)
+		if err != nil {
+			return err
+		}
+		defer reportFile.Close()
+		if err := services.WriteImportReport(reportFile, summary); err != nil {
+			return err
+		}
+		logger.Infof("rejected rows written to %s", reportPath)
+	}
+
+	// rejected rows are only written to the report, as they contain personal data
+	summary.Errors = nil
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"GolangCourse/internals/services"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// function to run the normalize-emails command, e.g.
+// go run . normalize-emails --dry-run
+// duplicates are printed with the ids of their users and fail the command, as they prevent the unique email index
+func RunNormalizeEmails(ctx context.Context, emservice services.EmailMigrationService, args []string) error {
+	flags := flag.NewFlagSet("normalize-emails", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report the changes without updating users")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	summary, err := emservice.NormalizeEmails(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		return err
+	}
+	if len(summary.Duplicates) > 0 {
+		return fmt.Errorf("%d emails are used by several users, update or delete the users and run the command again", len(summary.Duplicates))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package appauth
+
+import (
+	"GolangCourse/commons/apperrors"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// authenticator of client certificates, certificates are verified by the tls handshake
+// principals are mapped by common name of the subject, principals have the scopes of their mapping like api keys
+type CertificateAuthenticator struct {
+	scopes map[string][]string
+}
+
+// function to create the authenticator from comma separated "<common name>=<scope> <scope>"
+// e.g. "billing-service=users:read,crm-service=users:read users:write"
+func NewCertificateAuthenticator(mapping string) (*CertificateAuthenticator, error) {
+	authenticator := &CertificateAuthenticator{scopes: map[string][]string{}}
+	for _, entry := range strings.Split(mapping, ",") {
+		if len(strings.TrimSpace(entry)) == 0 {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || len(name) == 0 {
+			return nil, fmt.Errorf("invalid client certificate principal '%s', expected <common name>=<scopes>", entry)
+		}
+		scopes := strings.Fields(value)
+		for _, scope := range scopes {
+			if _, ok := scopePermissions[scope]; !ok {
+				return nil, fmt.Errorf("invalid scope '%s' of client certificate principal %s", scope, name)
+			}
+		}
+		authenticator.scopes[name] = scopes
+	}
+	return authenticator, nil
+}
+
+// function to get the principal of the verified client certificate, certificates which are not mapped are forbidden
+func (a *CertificateAuthenticator) Authenticate(certificate *x509.Certificate) (*Principal, error) {
+	name := certificate.Subject.CommonName
+	scopes, ok := a.scopes[name]
+	if !ok {
+		return nil, apperrors.NewForbidden("client certificate is not allowed")
+	}
+	return &Principal{
+		Subject: name,
+		Type:    PrincipalTypeCertificate,
+		Role:    RoleService,
+		Scopes:  slices.Clone(scopes),
+	}, nil
+}
@@ -0,0 +1,121 @@
+package appauth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// signer of access tokens issued by this service
+// tokens are signed with the signing key, or with the hmac secret when no signing key is configured
+type JwtSigner struct {
+	issuer   string
+	audience string
+	keyId    string
+	method   jwt.SigningMethod
+	key      interface{}
+}
+
+func NewJwtSigner(config *JwtConfig) (*JwtSigner, error) {
+	if len(strings.TrimSpace(config.Issuer)) == 0 || len(strings.TrimSpace(config.Audience)) == 0 {
+		return nil, errors.New("jwt issuer and audience are required")
+	}
+	signer := &JwtSigner{
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		keyId:    config.SigningKeyId,
+	}
+	switch {
+	case len(config.SigningKeyFile) > 0:
+		key, err := loadPrivateKey(config.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		switch privateKey := key.(type) {
+		case *rsa.PrivateKey:
+			signer.method, signer.key = jwt.SigningMethodRS256, privateKey
+		case ed25519.PrivateKey:
+			signer.method, signer.key = jwt.SigningMethodEdDSA, privateKey
+		default:
+			return nil, fmt.Errorf("unsupported jwt signing key type: %T", key)
+		}
+	case len(config.HmacSecretFile) > 0:
+		secret, err := readHmacSecret(config.HmacSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		signer.method, signer.key = jwt.SigningMethodHS256, secret
+	default:
+		return nil, errors.New("no jwt signing key is configured")
+	}
+	return signer, nil
+}
+
+// function to sign access token of the user, amr lists the authentication methods used
+func (s *JwtSigner) Sign(subject string, amr []string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(s.method, jwt.MapClaims{
+		"iss": s.issuer,
+		"aud": s.audience,
+		"sub": subject,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+		"jti": uuid.NewString(),
+		"amr": amr,
+	})
+	if len(s.keyId) > 0 {
+		token.Header["kid"] = s.keyId
+	}
+	return token.SignedString(s.key)
+}
+
+// function to get public key of the signing key, nil for hmac secrets
+func (s *JwtSigner) PublicKey() crypto.PublicKey {
+	if key, ok := s.key.(crypto.Signer); ok {
+		return key.Public()
+	}
+	return nil
+}
+
+// function to load RSA or Ed25519 private key from PEM file
+func loadPrivateKey(path string) (interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read jwt signing key: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("jwt signing key %s is not PEM encoded", path)
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt signing key: %w", err)
+	}
+	return key, nil
+}
+
+func readHmacSecret(path string) ([]byte, error) {
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read jwt hmac secret: %w", err)
+	}
+	trimmed := []byte(strings.TrimSpace(string(secret)))
+	if len(trimmed) < 32 {
+		return nil, errors.New("jwt hmac secret must be at least 32 bytes")
+	}
+	return trimmed, nil
+}
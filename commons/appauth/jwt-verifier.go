@@ -0,0 +1,305 @@
+package appauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// configuration of jwt bearer token verification, keys are loaded from files
+type JwtConfig struct {
+	Issuer         string
+	Audience       string
+	HmacSecretFile string
+	PublicKeyFile  string
+	JwksFile       string
+	Leeway         time.Duration
+	SigningKeyFile string
+	SigningKeyId   string
+}
+
+// verifier of HS256, RS256 and EdDSA signed jwt bearer tokens
+type JwtVerifier struct {
+	parser     *jwt.Parser
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	edKey      ed25519.PublicKey
+	jwks       map[string]interface{}
+}
+
+func NewJwtVerifier(config *JwtConfig) (*JwtVerifier, error) {
+	if len(strings.TrimSpace(config.Issuer)) == 0 || len(strings.TrimSpace(config.Audience)) == 0 {
+		return nil, errors.New("jwt issuer and audience are required")
+	}
+	verifier := &JwtVerifier{
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}),
+			jwt.WithIssuer(config.Issuer),
+			jwt.WithAudience(config.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(config.Leeway),
+		),
+		jwks: map[string]interface{}{},
+	}
+
+	if len(config.HmacSecretFile) > 0 {
+		secret, err := readHmacSecret(config.HmacSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier.hmacSecret = secret
+	}
+	if len(config.PublicKeyFile) > 0 {
+		key, err := loadPublicKey(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		switch publicKey := key.(type) {
+		case *rsa.PublicKey:
+			verifier.rsaKey = publicKey
+		case ed25519.PublicKey:
+			verifier.edKey = publicKey
+		default:
+			return nil, fmt.Errorf("unsupported jwt public key type: %T", key)
+		}
+	}
+	if len(config.SigningKeyFile) > 0 {
+		// tokens issued by this service are verified with the public key of the signing key
+		signer, err := NewJwtSigner(config)
+		if err != nil {
+			return nil, err
+		}
+		switch publicKey := signer.PublicKey().(type) {
+		case *rsa.PublicKey:
+			if len(config.SigningKeyId) > 0 {
+				verifier.jwks[config.SigningKeyId] = publicKey
+			} else if verifier.rsaKey == nil {
+				verifier.rsaKey = publicKey
+			}
+		case ed25519.PublicKey:
+			if len(config.SigningKeyId) > 0 {
+				verifier.jwks[config.SigningKeyId] = publicKey
+			} else if verifier.edKey == nil {
+				verifier.edKey = publicKey
+			}
+		}
+	}
+	if len(config.JwksFile) > 0 {
+		jwks, err := loadJwks(config.JwksFile)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range jwks {
+			verifier.jwks[kid] = key
+		}
+	}
+	if verifier.hmacSecret == nil && verifier.rsaKey == nil && verifier.edKey == nil && len(verifier.jwks) == 0 {
+		return nil, errors.New("no jwt verification key is configured")
+	}
+	return verifier, nil
+}
+
+// function to verify the token and get the principal from its claims
+func (v *JwtVerifier) Verify(tokenString string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(tokenString, claims, v.getKey); err != nil {
+		return nil, err
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || len(subject) == 0 {
+		return nil, errors.New("token has no subject")
+	}
+	return &Principal{
+		Subject: subject,
+		Type:    PrincipalTypeUser,
+		Scopes:  getScopes(claims),
+		Amr:     getStrings(claims["amr"]),
+	}, nil
+}
+
+// function to get verification key of the token, by key id or by algorithm
+func (v *JwtVerifier) getKey(token *jwt.Token) (interface{}, error) {
+	algorithm := token.Method.Alg()
+	if kid, _ := token.Header["kid"].(string); len(kid) > 0 {
+		if key, ok := v.jwks[kid]; ok {
+			if !isKeyForAlgorithm(key, algorithm) {
+				return nil, fmt.Errorf("key '%s' cannot be used with %s", kid, algorithm)
+			}
+			return key, nil
+		}
+	}
+	var key interface{}
+	switch algorithm {
+	case "HS256":
+		if v.hmacSecret != nil {
+			key = v.hmacSecret
+		}
+	case "RS256":
+		if v.rsaKey != nil {
+			key = v.rsaKey
+		}
+	case "EdDSA":
+		if v.edKey != nil {
+			key = v.edKey
+		}
+	}
+	if key == nil {
+		// without key id, a single jwks key of the algorithm is used
+		for _, jwksKey := range v.jwks {
+			if isKeyForAlgorithm(jwksKey, algorithm) {
+				if key != nil {
+					return nil, errors.New("token has no key id, multiple keys match")
+				}
+				key = jwksKey
+			}
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no key configured for %s", algorithm)
+	}
+	return key, nil
+}
+
+func isKeyForAlgorithm(key interface{}, algorithm string) bool {
+	switch key.(type) {
+	case []byte:
+		return algorithm == "HS256"
+	case *rsa.PublicKey:
+		return algorithm == "RS256"
+	case ed25519.PublicKey:
+		return algorithm == "EdDSA"
+	}
+	return false
+}
+
+// function to get scopes from space separated "scope" claim or "scp" array claim
+func getScopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	return getStrings(claims["scp"])
+}
+
+// function to get string values of array claim
+func getStrings(claim interface{}) []string {
+	var values []string
+	if items, ok := claim.([]interface{}); ok {
+		for _, item := range items {
+			if value, ok := item.(string); ok {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// function to load RSA or Ed25519 public key from PEM file, certificates are accepted as well
+func loadPublicKey(path string) (interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read jwt public key: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("jwt public key %s is not PEM encoded", path)
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwt certificate: %w", err)
+		}
+		return certificate.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt public key: %w", err)
+	}
+	return key, nil
+}
+
+// json web key, only the members of supported key types
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	K   string `json:"k"`
+}
+
+// function to load keys from JWKS file, keyed by key id
+func loadJwks(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read jwks: %w", err)
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %w", err)
+	}
+	keys := map[string]interface{}{}
+	for index, jwk := range jwks.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+		kid := jwk.Kid
+		if len(kid) == 0 {
+			kid = fmt.Sprintf("#%d", index)
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwks key '%s': %w", kid, err)
+		}
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return decode(k.K)
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+}
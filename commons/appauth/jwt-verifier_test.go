@@ -0,0 +1,154 @@
+package appauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testHmacSecret = "0123456789abcdef0123456789abcdef"
+
+// function to write the content to a file of the test directory
+func writeTestFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testClaims(subject string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   subject,
+		"iss":   "issuer",
+		"aud":   "audience",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"scope": "users:read users:write",
+	}
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key interface{}) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if len(kid) > 0 {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJwtVerifierPinsAlgorithmsToKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherEdPublic, otherEdPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "OKP", "crv": "Ed25519", "kid": "ed-1", "x": encode(edPublic)},
+		{"kty": "OKP", "crv": "Ed25519", "kid": "ed-2", "x": encode(otherEdPublic)},
+		{"kty": "oct", "kid": "oct-1", "k": encode([]byte(testHmacSecret))},
+	}})
+
+	rsaVerifier, err := NewJwtVerifier(&JwtConfig{Issuer: "issuer", Audience: "audience", PublicKeyFile: writeTestFile(t, "public.pem", publicPem)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacVerifier, err := NewJwtVerifier(&JwtConfig{Issuer: "issuer", Audience: "audience", HmacSecretFile: writeTestFile(t, "secret", []byte(testHmacSecret))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksVerifier, err := NewJwtVerifier(&JwtConfig{Issuer: "issuer", Audience: "audience", JwksFile: writeTestFile(t, "jwks.json", jwks)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := testClaims("user-1")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongAudience := testClaims("user-1")
+	wrongAudience["aud"] = "other"
+	withoutSubject := testClaims("")
+
+	tests := []struct {
+		name     string
+		verifier *JwtVerifier
+		token    string
+		valid    bool
+	}{
+		{"rs256 with public key", rsaVerifier, signTestToken(t, jwt.SigningMethodRS256, "", testClaims("user-1"), rsaKey), true},
+		{"hs256 signed with rsa public key", rsaVerifier, signTestToken(t, jwt.SigningMethodHS256, "", testClaims("user-1"), publicPem), false},
+		{"none algorithm", rsaVerifier, signTestToken(t, jwt.SigningMethodNone, "", testClaims("user-1"), jwt.UnsafeAllowNoneSignatureType), false},
+		{"hs256 with secret", hmacVerifier, signTestToken(t, jwt.SigningMethodHS256, "", testClaims("user-1"), []byte(testHmacSecret)), true},
+		{"hs384 with secret", hmacVerifier, signTestToken(t, jwt.SigningMethodHS384, "", testClaims("user-1"), []byte(testHmacSecret)), false},
+		{"expired", hmacVerifier, signTestToken(t, jwt.SigningMethodHS256, "", expired, []byte(testHmacSecret)), false},
+		{"wrong audience", hmacVerifier, signTestToken(t, jwt.SigningMethodHS256, "", wrongAudience, []byte(testHmacSecret)), false},
+		{"without subject", hmacVerifier, signTestToken(t, jwt.SigningMethodHS256, "", withoutSubject, []byte(testHmacSecret)), false},
+		{"eddsa with key id", jwksVerifier, signTestToken(t, jwt.SigningMethodEdDSA, "ed-2", testClaims("user-1"), otherEdPrivate), true},
+		{"eddsa with key id of other key", jwksVerifier, signTestToken(t, jwt.SigningMethodEdDSA, "ed-1", testClaims("user-1"), otherEdPrivate), false},
+		{"eddsa with key id of hmac key", jwksVerifier, signTestToken(t, jwt.SigningMethodEdDSA, "oct-1", testClaims("user-1"), edPrivate), false},
+		{"hs256 with key id of eddsa key", jwksVerifier, signTestToken(t, jwt.SigningMethodHS256, "ed-1", testClaims("user-1"), []byte(testHmacSecret)), false},
+		{"eddsa without key id and multiple keys", jwksVerifier, signTestToken(t, jwt.SigningMethodEdDSA, "", testClaims("user-1"), edPrivate), false},
+		{"hs256 without key id and single key", jwksVerifier, signTestToken(t, jwt.SigningMethodHS256, "", testClaims("user-1"), []byte(testHmacSecret)), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := test.verifier.Verify(test.token)
+			if test.valid != (err == nil) {
+				t.Fatalf("expected valid %t, got error: %v", test.valid, err)
+			}
+			if !test.valid {
+				return
+			}
+			if principal.Subject != "user-1" || principal.Type != PrincipalTypeUser {
+				t.Fatalf("unexpected principal: %+v", principal)
+			}
+			if len(principal.Scopes) != 2 || principal.Scopes[1] != ScopeUsersWrite {
+				t.Fatalf("unexpected scopes: %v", principal.Scopes)
+			}
+		})
+	}
+}
+
+func TestNewJwtVerifierRequiresKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *JwtConfig
+	}{
+		{"without issuer", &JwtConfig{Audience: "audience", HmacSecretFile: writeTestFile(t, "secret", []byte(testHmacSecret))}},
+		{"without key", &JwtConfig{Issuer: "issuer", Audience: "audience"}},
+		{"short hmac secret", &JwtConfig{Issuer: "issuer", Audience: "audience", HmacSecretFile: writeTestFile(t, "short", []byte("secret"))}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewJwtVerifier(test.config); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package appauth
+
+import (
+	"GolangCourse/commons/apperrors"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2id parameters of new password hashes, stored hashes keep their own parameters
+const (
+	argonTime     = 3
+	argonMemory   = 64 * 1024
+	argonThreads  = 2
+	argonKeySize  = 32
+	argonSaltSize = 16
+
+	passwordMaxLength = 128
+)
+
+// password policy, passwords must have minimum length and number of character classes
+// classes are lower case, upper case, digits and symbols
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int
+}
+
+// function to validate password against the policy, the password must not be the email
+func (p *PasswordPolicy) Validate(password string, email string) error {
+	var message string
+	length := utf8.RuneCountInString(password)
+	switch {
+	case length < p.MinLength:
+		message = fmt.Sprintf("must be at least %d characters", p.MinLength)
+	case length > passwordMaxLength:
+		message = fmt.Sprintf("must be at most %d characters", passwordMaxLength)
+	case passwordClasses(password) < p.MinClasses:
+		message = fmt.Sprintf("must contain at least %d of lower case, upper case, digit and symbol characters", p.MinClasses)
+	case len(email) > 0 && strings.EqualFold(strings.TrimSpace(password), email):
+		message = "must not be the email"
+	default:
+		return nil
+	}
+	return apperrors.NewInvalidArgument("invalid password").WithDetails(map[string]interface{}{
+		"password": message,
+	})
+}
+
+func passwordClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, char := range password {
+		switch {
+		case unicode.IsLower(char):
+			lower = 1
+		case unicode.IsUpper(char):
+			upper = 1
+		case unicode.IsDigit(char):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// function to hash password with argon2id, hash is encoded in PHC string format
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeySize)
+	encoding := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argonMemory, argonTime, argonThreads,
+		encoding.EncodeToString(salt), encoding.EncodeToString(key)), nil
+}
+
+// function to verify password against argon2id or bcrypt hash, bcrypt hashes are accepted for migrated credentials
+func VerifyPassword(password string, hash string) (bool, error) {
+	if strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$") {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, errors.New("unsupported password hash")
+	}
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, errors.New("unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	encoding := base64.RawStdEncoding
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	computed := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, computed) == 1, nil
+}
+
+// function to check if hash must be replaced by a hash with current parameters
+func NeedsRehash(hash string) bool {
+	return !strings.HasPrefix(hash, fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$", argon2.Version, argonMemory, argonTime, argonThreads))
+}
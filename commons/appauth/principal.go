@@ -0,0 +1,68 @@
+package appauth
+
+import (
+	"context"
+	"slices"
+)
+
+const (
+	PrincipalTypeUser   = "user"
+	PrincipalTypeApiKey = "api_key"
+	// internal callers which authenticate with client certificates
+	PrincipalTypeCertificate = "certificate"
+	// commands and requests of local development with authentication disabled
+	PrincipalTypeSystem = "system"
+)
+
+// scopes of api keys
+const (
+	ScopeUsersRead   = "users:read"
+	ScopeUsersWrite  = "users:write"
+	ScopeUsersDelete = "users:delete"
+	// changes the types of users, types are the roles of users
+	ScopeUsersAdmin = "users:admin"
+)
+
+type principalKey struct{}
+
+// authenticated caller of the request
+type Principal struct {
+	Subject string   `json:"subject"`
+	Type    string   `json:"type"`
+	Role    string   `json:"role,omitempty"`
+	Scopes  []string `json:"scopes,omitempty"`
+	Amr     []string `json:"amr,omitempty"`
+}
+
+// function to check if principal has the scope
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// function to check if principal authenticated with a second factor, e.g. one-time password
+func (p *Principal) HasMfa() bool {
+	return slices.Contains(p.Amr, "otp") || slices.Contains(p.Amr, "mfa")
+}
+
+// function to get loggable identity of principal
+func (p *Principal) String() string {
+	return p.Type + ":" + p.Subject
+}
+
+// function to create principal of commands and of local development, it has the admin role
+func NewSystemPrincipal(name string) *Principal {
+	return &Principal{Subject: name, Type: PrincipalTypeSystem, Role: RoleAdmin}
+}
+
+// function to set principal in the context
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// function to get principal from the context, nil is returned for anonymous requests
+func GetPrincipal(ctx context.Context) *Principal {
+	if principal, ok := ctx.Value(principalKey{}).(*Principal); ok {
+		return principal
+	}
+	return nil
+}
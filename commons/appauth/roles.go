@@ -0,0 +1,104 @@
+package appauth
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"context"
+	"slices"
+)
+
+// roles of principals, user roles are the types of users
+const (
+	RoleAdmin   = "admin"
+	RoleUser    = "user"
+	RoleGuest   = "guest"
+	RoleService = "service"
+)
+
+// action a principal is allowed to perform
+type Permission string
+
+const (
+	PermissionReadUsers     Permission = "users:read"
+	PermissionReadSelf      Permission = "users:read:self"
+	PermissionWriteUsers    Permission = "users:write"
+	PermissionUpdateSelf    Permission = "users:update:self"
+	PermissionDeleteUsers   Permission = "users:delete"
+	PermissionChangeType    Permission = "users:change_type"
+	PermissionManageApiKeys Permission = "api_keys:manage"
+	PermissionSetPassword   Permission = "users:set_password"
+	PermissionEnrollMfa     Permission = "users:enroll_mfa"
+	PermissionResetMfa      Permission = "users:reset_mfa"
+	PermissionManageLogs    Permission = "logs:manage"
+	PermissionReadLogs      Permission = "logs:read"
+)
+
+// permissions of user roles, permissions of api keys and client certificates are derived from their scopes
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermissionReadUsers, PermissionReadSelf, PermissionWriteUsers, PermissionUpdateSelf,
+		PermissionDeleteUsers, PermissionChangeType, PermissionManageApiKeys, PermissionSetPassword,
+		PermissionResetMfa, PermissionManageLogs, PermissionReadLogs,
+	},
+	RoleUser:  {PermissionReadSelf, PermissionUpdateSelf},
+	RoleGuest: {PermissionReadSelf},
+}
+
+var scopePermissions = map[string][]Permission{
+	ScopeUsersRead:   {PermissionReadUsers},
+	ScopeUsersWrite:  {PermissionWriteUsers},
+	ScopeUsersDelete: {PermissionDeleteUsers},
+	ScopeUsersAdmin:  {PermissionChangeType},
+}
+
+// permission which grants the action on the own user of the principal
+var selfPermissions = map[Permission]Permission{
+	PermissionReadUsers:   PermissionReadSelf,
+	PermissionWriteUsers:  PermissionUpdateSelf,
+	PermissionSetPassword: PermissionUpdateSelf,
+	// second factors are only enrolled by the users themselves
+	PermissionEnrollMfa: PermissionUpdateSelf,
+}
+
+// function to check if principal has the permission
+func (p *Principal) HasPermission(permission Permission) bool {
+	if p.Type == PrincipalTypeApiKey || p.Type == PrincipalTypeCertificate {
+		for _, scope := range p.Scopes {
+			if slices.Contains(scopePermissions[scope], permission) {
+				return true
+			}
+		}
+		return false
+	}
+	return slices.Contains(rolePermissions[p.Role], permission)
+}
+
+// function to authorize the action of the principal of the context on the user with userId
+// userId is empty for actions which are not on a single user, e.g. listing users
+// requests without principal are not authorized, commands and local development use the system principal
+func Authorize(ctx context.Context, permission Permission, userId string) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	principal := GetPrincipal(ctx)
+	if principal == nil {
+		logger.Warnf("authorization denied, request without principal, permission: %s, userId: %s", permission, userId)
+		return apperrors.NewUnauthorized("authentication is required")
+	}
+	allowed := principal.HasPermission(permission)
+	if !allowed && len(userId) > 0 && principal.Type == PrincipalTypeUser && principal.Subject == userId {
+		if selfPermission, ok := selfPermissions[permission]; ok {
+			allowed = principal.HasPermission(selfPermission)
+		}
+	}
+	if !allowed {
+		logger.Warnf("authorization denied, principal: %s, role: %s, permission: %s, userId: %s", principal, principal.Role, permission, userId)
+		return apperrors.NewForbidden("not allowed to " + string(permission))
+	}
+	logger.Infof("authorization allowed, principal: %s, role: %s, permission: %s, userId: %s", principal, principal.Role, permission, userId)
+	return nil
+}
+
+// function to check if the principal of the context has the permission, without logging the decision
+func IsAllowed(ctx context.Context, permission Permission) bool {
+	principal := GetPrincipal(ctx)
+	return principal != nil && principal.HasPermission(permission)
+}
@@ -0,0 +1,70 @@
+package appauth
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"testing"
+)
+
+func TestScopePermissions(t *testing.T) {
+	tests := []struct {
+		scope      string
+		permission Permission
+		allowed    bool
+	}{
+		{ScopeUsersRead, PermissionReadUsers, true},
+		{ScopeUsersRead, PermissionWriteUsers, false},
+		{ScopeUsersWrite, PermissionWriteUsers, true},
+		{ScopeUsersWrite, PermissionDeleteUsers, false},
+		{ScopeUsersWrite, PermissionChangeType, false},
+		{ScopeUsersDelete, PermissionDeleteUsers, true},
+		{ScopeUsersDelete, PermissionWriteUsers, false},
+		{ScopeUsersAdmin, PermissionChangeType, true},
+		{ScopeUsersAdmin, PermissionManageApiKeys, false},
+	}
+	for _, test := range tests {
+		t.Run(test.scope+" "+string(test.permission), func(t *testing.T) {
+			principal := &Principal{Subject: "key", Type: PrincipalTypeApiKey, Role: RoleService, Scopes: []string{test.scope}}
+			if allowed := principal.HasPermission(test.permission); allowed != test.allowed {
+				t.Fatalf("expected allowed %t, got %t", test.allowed, allowed)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx, _ := apploggers.NewLogger()
+	tests := []struct {
+		name       string
+		principal  *Principal
+		permission Permission
+		userId     string
+		kind       apperrors.ErrorKind
+	}{
+		{"without principal", nil, PermissionReadSelf, "user-1", apperrors.Unauthorized},
+		{"admin", &Principal{Subject: "admin-1", Type: PrincipalTypeUser, Role: RoleAdmin}, PermissionDeleteUsers, "user-1", ""},
+		{"user on own user", &Principal{Subject: "user-1", Type: PrincipalTypeUser, Role: RoleUser}, PermissionWriteUsers, "user-1", ""},
+		{"user on other user", &Principal{Subject: "user-2", Type: PrincipalTypeUser, Role: RoleUser}, PermissionWriteUsers, "user-1", apperrors.Forbidden},
+		{"guest on own user", &Principal{Subject: "user-1", Type: PrincipalTypeUser, Role: RoleGuest}, PermissionWriteUsers, "user-1", apperrors.Forbidden},
+		{"api key with subject of user", &Principal{Subject: "user-1", Type: PrincipalTypeApiKey, Role: RoleService, Scopes: []string{ScopeUsersRead}}, PermissionWriteUsers, "user-1", apperrors.Forbidden},
+		{"system", NewSystemPrincipal("import-command"), PermissionChangeType, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			context := ctx
+			if test.principal != nil {
+				context = WithPrincipal(ctx, test.principal)
+			}
+			err := Authorize(context, test.permission, test.userId)
+			if len(test.kind) == 0 {
+				if err != nil {
+					t.Fatalf("expected allowed, got: %v", err)
+				}
+				return
+			}
+			if kind := apperrors.KindOf(err); kind != test.kind {
+				t.Fatalf("expected %q, got %q: %v", test.kind, kind, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package appauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefix of encrypted secrets, the version of the encryption
+const encryptedSecretPrefix = "v1:"
+
+// cipher of secrets at rest, e.g. totp secrets, with AES-256-GCM, and keyed hashes of random codes with HMAC-SHA256
+// secrets and hashes are bound to the associated data, so that they cannot be copied to another user
+type SecretCipher struct {
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+// function to load the cipher from the key file, the key must be at least 32 bytes
+func LoadSecretCipher(path string) (*SecretCipher, error) {
+	if len(strings.TrimSpace(path)) == 0 {
+		return nil, errors.New("secret key file is required")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read secret key: %w", err)
+	}
+	trimmed := []byte(strings.TrimSpace(string(content)))
+	if len(trimmed) < 32 {
+		return nil, errors.New("secret key must be at least 32 bytes")
+	}
+	key := sha256.Sum256(trimmed)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	hashKey := hmac.New(sha256.New, trimmed)
+	hashKey.Write([]byte("hash key"))
+	return &SecretCipher{aead: aead, hashKey: hashKey.Sum(nil)}, nil
+}
+
+// function to encrypt the secret, the nonce is stored with the ciphertext
+func (s *SecretCipher) Encrypt(secret string, associated string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(secret), []byte(associated))
+	return encryptedSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// function to decrypt the secret, values which are not encrypted are rejected
+func (s *SecretCipher) Decrypt(value string, associated string) (string, error) {
+	if !strings.HasPrefix(value, encryptedSecretPrefix) {
+		return "", errors.New("secret is not encrypted")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, encryptedSecretPrefix))
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("invalid encrypted secret")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	secret, err := s.aead.Open(nil, nonce, ciphertext, []byte(associated))
+	if err != nil {
+		return "", errors.New("cannot decrypt secret, the key has changed or the secret belongs to another user")
+	}
+	return string(secret), nil
+}
+
+// function to hash random codes, e.g. recovery codes, codes have high entropy so a slow hash is not needed
+func (s *SecretCipher) Hash(code string, associated string) string {
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write([]byte(associated))
+	mac.Write([]byte{0})
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
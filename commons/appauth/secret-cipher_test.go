@@ -0,0 +1,90 @@
+package appauth
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCipherKey = "0123456789abcdef0123456789abcdef"
+
+func newTestCipher(t *testing.T, key string) *SecretCipher {
+	t.Helper()
+	cipher, err := LoadSecretCipher(writeTestFile(t, "totp.key", []byte(key+"\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+func TestSecretCipherRoundTrip(t *testing.T) {
+	cipher := newTestCipher(t, testCipherKey)
+	encrypted, err := cipher.Encrypt("JBSWY3DPEHPK3PXP", "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, encryptedSecretPrefix) || strings.Contains(encrypted, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("secret must be encrypted: %s", encrypted)
+	}
+	again, _ := cipher.Encrypt("JBSWY3DPEHPK3PXP", "user-1")
+	if again == encrypted {
+		t.Fatal("nonces must differ between encryptions")
+	}
+	secret, err := cipher.Decrypt(encrypted, "user-1")
+	if err != nil || secret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("unexpected secret %q: %v", secret, err)
+	}
+}
+
+func TestSecretCipherRejectsInvalidValues(t *testing.T) {
+	cipher := newTestCipher(t, testCipherKey)
+	encrypted, err := cipher.Encrypt("JBSWY3DPEHPK3PXP", "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := []byte(encrypted)
+	sealed[len(sealed)-1] ^= 1
+	tests := []struct {
+		name   string
+		cipher *SecretCipher
+		value  string
+		userId string
+	}{
+		{"plain secret", cipher, "JBSWY3DPEHPK3PXP", "user-1"},
+		{"other user", cipher, encrypted, "user-2"},
+		{"tampered", cipher, string(sealed), "user-1"},
+		{"truncated", cipher, encrypted[:len(encryptedSecretPrefix)+4], "user-1"},
+		{"other key", newTestCipher(t, strings.Repeat("k", 32)), encrypted, "user-1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if secret, err := test.cipher.Decrypt(test.value, test.userId); err == nil {
+				t.Fatalf("expected error, got secret %q", secret)
+			}
+		})
+	}
+}
+
+func TestSecretCipherHash(t *testing.T) {
+	cipher := newTestCipher(t, testCipherKey)
+	hash := cipher.Hash("1a2b3-c4d5e-6f7a8-b9c0d", "user-1")
+	if hash != cipher.Hash("1a2b3-c4d5e-6f7a8-b9c0d", "user-1") {
+		t.Fatal("hashes must be deterministic so that codes are looked up by hash")
+	}
+	if hash == cipher.Hash("1a2b3-c4d5e-6f7a8-b9c0d", "user-2") || hash == newTestCipher(t, strings.Repeat("k", 32)).Hash("1a2b3-c4d5e-6f7a8-b9c0d", "user-1") {
+		t.Fatal("hashes must depend on the user and the key")
+	}
+}
+
+func TestLoadSecretCipherRequiresKey(t *testing.T) {
+	for name, path := range map[string]string{
+		"without file": "",
+		"missing file": writeTestFile(t, "unused", nil) + ".missing",
+		"short key":    writeTestFile(t, "short.key", []byte(strings.Repeat("k", 31))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadSecretCipher(path); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package appauth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// secret of the test vectors of RFC 6238, appendix B
+var rfcTotpKey = []byte("12345678901234567890")
+
+func TestTotpCodeMatchesRfcVectors(t *testing.T) {
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, test := range tests {
+		step := test.time / totpPeriod
+		if code := totpCode(rfcTotpKey, step, 8); code != test.code {
+			t.Errorf("time %d: expected %s, got %s", test.time, test.code, code)
+		}
+		// codes of 6 digits are the last digits of the 8 digit codes
+		if code := totpCode(rfcTotpKey, step, 6); code != test.code[2:] {
+			t.Errorf("time %d: expected %s, got %s", test.time, test.code[2:], code)
+		}
+	}
+}
+
+func TestValidateTotp(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfcTotpKey)
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	tests := []struct {
+		name  string
+		code  string
+		step  int64
+		valid bool
+	}{
+		{"current step", totpCode(rfcTotpKey, current, totpDigits), current, true},
+		{"previous step", totpCode(rfcTotpKey, current-1, totpDigits), current - 1, true},
+		{"next step", totpCode(rfcTotpKey, current+1, totpDigits), current + 1, true},
+		{"code with spaces", " " + totpCode(rfcTotpKey, current, totpDigits)[:3] + " " + totpCode(rfcTotpKey, current, totpDigits)[3:], current, true},
+		{"outside the skew", totpCode(rfcTotpKey, current-2, totpDigits), 0, false},
+		{"8 digits", totpCode(rfcTotpKey, current, 8), 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, valid := ValidateTotp(secret, test.code, now)
+			if valid != test.valid || step != test.step {
+				t.Fatalf("expected step %d valid %t, got step %d valid %t", test.step, test.valid, step, valid)
+			}
+		})
+	}
+	if _, valid := ValidateTotp("not base32!", "123456", now); valid {
+		t.Fatal("invalid secrets must not validate")
+	}
+}
+
+func TestTotpUri(t *testing.T) {
+	uri := TotpUri("User Management", "ada@example.com", "SECRET")
+	if !strings.HasPrefix(uri, "otpauth://totp/User%20Management:ada@example.com?") {
+		t.Fatalf("unexpected label: %s", uri)
+	}
+	for _, parameter := range []string{"secret=SECRET", "issuer=User%20Management", "digits=6", "period=30"} {
+		if !strings.Contains(uri, parameter) {
+			t.Fatalf("missing %s: %s", parameter, uri)
+		}
+	}
+}
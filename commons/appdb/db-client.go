@@ -29,9 +29,9 @@ func (d *dbclient) Disconnect(ctx context.Context) {
 	d.client.Disconnect(ctx)
 }
 
-// function to get collection for the database
+// function to get collection for the database, operations of the collection are traced
 func (d *dbclient) Collection(collection string) DatabaseCollection {
-	return newDatabaseCollection(d.client.Database(d.databaseName), collection)
+	return newTracedCollection(newDatabaseCollection(d.client.Database(d.databaseName), collection), d.databaseName, collection)
 }
 
 // function to get database name
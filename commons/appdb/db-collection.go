@@ -2,25 +2,30 @@ package appdb
 
 import (
 	"context"
+	"errors"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type DatabaseCollection interface {
-	FindOne(ctx context.Context, filter interface{}, document interface{}) error
+	FindOne(ctx context.Context, filter interface{}, document interface{}, opts ...*options.FindOneOptions) error
 	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}) error
 	InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
 	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 	CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
 	Find(ctx context.Context, filter interface{}, options *options.FindOptions, response interface{}) error
+	FindCursor(ctx context.Context, filter interface{}, options *options.FindOptions) (*mongo.Cursor, error)
 	Aggregate(ctx context.Context, pipeline interface{}, response interface{}) error
 	DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
 	DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
 	Distinct(ctx context.Context, field string, response interface{}) ([]interface{}, error)
 	Drop(ctx context.Context) error
 	InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error)
+	BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+	CreateIndex(ctx context.Context, model mongo.IndexModel) (string, error)
+	DropIndex(ctx context.Context, name string) error
 }
 
 type dbcollection struct {
@@ -41,8 +46,8 @@ func (d *dbcollection) DeleteMany(ctx context.Context, filter interface{}, opts
 	return d.collection.DeleteMany(ctx, filter, opts...)
 }
 
-func (d *dbcollection) FindOne(ctx context.Context, filter interface{}, document interface{}) error {
-	return d.collection.FindOne(ctx, filter).Decode(document)
+func (d *dbcollection) FindOne(ctx context.Context, filter interface{}, document interface{}, opts ...*options.FindOneOptions) error {
+	return d.collection.FindOne(ctx, filter, opts...).Decode(document)
 }
 
 func (d *dbcollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}) error {
@@ -81,6 +86,11 @@ func (d *dbcollection) Find(
 	return results.All(ctx, response)
 }
 
+// function to get cursor for the matching documents, caller must close the cursor
+func (d *dbcollection) FindCursor(ctx context.Context, filter interface{}, options *options.FindOptions) (*mongo.Cursor, error) {
+	return d.collection.Find(ctx, filter, options)
+}
+
 func (d *dbcollection) Drop(ctx context.Context) error {
 	return d.collection.Drop(ctx)
 }
@@ -102,3 +112,21 @@ func (d *dbcollection) Distinct(
 func (d *dbcollection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
 	return d.collection.InsertMany(ctx, documents, opts...)
 }
+
+func (d *dbcollection) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	return d.collection.BulkWrite(ctx, models, opts...)
+}
+
+func (d *dbcollection) CreateIndex(ctx context.Context, model mongo.IndexModel) (string, error) {
+	return d.collection.Indexes().CreateOne(ctx, model)
+}
+
+// function to drop the index, dropping an index which does not exist is not an error
+func (d *dbcollection) DropIndex(ctx context.Context, name string) error {
+	_, err := d.collection.Indexes().DropOne(ctx, name)
+	var commandError mongo.CommandError
+	if errors.As(err, &commandError) && commandError.Name == "IndexNotFound" {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package appdb
+
+import (
+	"context"
+	"time"
+
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/commons/appmetrics"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// configuration of the mongo connection, credentials are only set when user is not empty
+type Config struct {
+	Uri            string
+	User           string
+	Password       string
+	Database       string
+	ConnectTimeout time.Duration
+}
+
+// function to connect to the database, the connection is checked by a ping, so that startup fails on wrong configuration
+func Connect(ctx context.Context, config *Config) (DatabaseClient, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
+	opts := options.Client().ApplyURI(config.Uri).SetServerAPIOptions(serverAPI).SetPoolMonitor(appmetrics.NewPoolMonitor())
+	if len(config.User) > 0 {
+		opts.SetAuth(options.Credential{Username: config.User, Password: config.Password})
+	}
+	client, cerror := mongo.Connect(ctx, opts)
+	if cerror != nil {
+		return nil, cerror
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, config.ConnectTimeout)
+	defer cancel()
+	if perror := client.Ping(pingCtx, nil); perror != nil {
+		client.Disconnect(ctx) //nolint
+		return nil, perror
+	}
+	logger.Infof("connected to database: %s", config.Database)
+	return NewDatabaseClient(config.Database, client), nil
+}
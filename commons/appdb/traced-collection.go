@@ -0,0 +1,161 @@
+package appdb
+
+import (
+	"GolangCourse/commons/apptracing"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// collection which records a client span of every operation, filters and documents are not recorded as they contain user data
+type tracedcollection struct {
+	delegate   DatabaseCollection
+	database   string
+	collection string
+}
+
+func newTracedCollection(delegate DatabaseCollection, database string, collection string) DatabaseCollection {
+	return &tracedcollection{
+		delegate:   delegate,
+		database:   database,
+		collection: collection,
+	}
+}
+
+func (t *tracedcollection) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return apptracing.Start(ctx, "mongodb "+operation+" "+t.collection, trace.SpanKindClient,
+		semconv.DBSystemMongoDB,
+		semconv.DBNamespace(t.database),
+		semconv.DBCollectionName(t.collection),
+		semconv.DBOperationName(operation),
+	)
+}
+
+func (t *tracedcollection) FindOne(ctx context.Context, filter interface{}, document interface{}, opts ...*options.FindOneOptions) error {
+	ctx, span := t.start(ctx, "findOne")
+	err := t.delegate.FindOne(ctx, filter, document, opts...)
+	// missing documents are expected, e.g. on lookup by email
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		apptracing.End(span, nil)
+	} else {
+		apptracing.End(span, err)
+	}
+	return err
+}
+
+func (t *tracedcollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}) error {
+	ctx, span := t.start(ctx, "findOneAndUpdate")
+	err := t.delegate.FindOneAndUpdate(ctx, filter, update)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *tracedcollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	ctx, span := t.start(ctx, "insertOne")
+	result, err := t.delegate.InsertOne(ctx, document, opts...)
+	apptracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedcollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	ctx, span := t.start(ctx, "updateOne")
+	result, err := t.delegate.UpdateOne(ctx, filter, update, opts...)
+	apptracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedcollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	ctx, span := t.start(ctx, "updateMany")
+	result, err := t.delegate.UpdateMany(ctx, filter, update, opts...)
+	apptracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedcollection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	ctx, span := t.start(ctx, "countDocuments")
+	count, err := t.delegate.CountDocuments(ctx, filter, opts...)
+	apptracing.End(span, err)
+	return count, err
+}
+
+func (t *tracedcollection) Find(ctx context.Context, filter interface{}, options *options.FindOptions, response interface{}) error {
+	ctx, span := t.start(ctx, "find")
+	err := t.delegate.Find(ctx, filter, options, response)
+	apptracing.End(span, err)
+	return err
+}
+
+// span of the cursor only covers the first batch, further batches are loaded by the caller
+func (t *tracedcollection) FindCursor(ctx context.Context, filter interface{}, options *options.FindOptions) (*mongo.Cursor, error) {
+	ctx, span := t.start(ctx, "find")
+	cursor, err := t.delegate.FindCursor(ctx, filter, options)
+	apptracing.End(span, err)
+	return cursor, err
+}
+
+func (t *tracedcollection) Aggregate(ctx context.Context, pipeline interface{}, response interface{}) error {
+	ctx, span := t.start(ctx, "aggregate")
+	err := t.delegate.Aggregate(ctx, pipeline, response)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *tracedcollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	ctx, span := t.start(ctx, "deleteOne")
+	result, err := t.delegate.DeleteOne(ctx, filter, opts...)
+	apptracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedcollection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	ctx, span := t.start(ctx, "deleteMany")
+	result, err := t.delegate.DeleteMany(ctx, filter, opts...)
+	apptracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedcollection) Distinct(ctx context.Context, field string, response interface{}) ([]interface{}, error) {
+	ctx, span := t.start(ctx, "distinct")
+	values, err := t.delegate.Distinct(ctx, field, response)
+	apptracing.End(span, err)
+	return values, err
+}
+
+func (t *tracedcollection) Drop(ctx context.Context) error {
+	ctx, span := t.start(ctx, "drop")
+	err := t.delegate.Drop(ctx)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *tracedcollection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	ctx, span := t.start(ctx, "insertMany")
+	result, err := t.delegate.InsertMany(ctx, documents, opts...)
+	apptracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedcollection) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	ctx, span := t.start(ctx, "bulkWrite")
+	result, err := t.delegate.BulkWrite(ctx, models, opts...)
+	apptracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedcollection) CreateIndex(ctx context.Context, model mongo.IndexModel) (string, error) {
+	ctx, span := t.start(ctx, "createIndexes")
+	name, err := t.delegate.CreateIndex(ctx, model)
+	apptracing.End(span, err)
+	return name, err
+}
+
+func (t *tracedcollection) DropIndex(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "dropIndexes")
+	err := t.delegate.DropIndex(ctx, name)
+	apptracing.End(span, err)
+	return err
+}
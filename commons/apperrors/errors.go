@@ -0,0 +1,116 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// kind of domain error, used to map errors to the api response status
+type ErrorKind string
+
+const (
+	InvalidArgument      ErrorKind = "invalid_argument"
+	Unauthorized         ErrorKind = "unauthorized"
+	Forbidden            ErrorKind = "forbidden"
+	NotFound             ErrorKind = "not_found"
+	Conflict             ErrorKind = "conflict"
+	UnsupportedMediaType ErrorKind = "unsupported_media_type"
+	PayloadTooLarge      ErrorKind = "payload_too_large"
+	RateLimited          ErrorKind = "rate_limited"
+	Unavailable          ErrorKind = "unavailable"
+	Timeout              ErrorKind = "timeout"
+	Internal             ErrorKind = "internal"
+)
+
+// domain error returned by db and service layers
+// message and details are safe to return to the caller, the cause is internal and only logged
+type AppError struct {
+	Kind    ErrorKind
+	Message string
+	Details map[string]interface{}
+	Cause   error
+}
+
+func (e *AppError) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Cause
+}
+
+// function to add details for the caller to the error
+func (e *AppError) WithDetails(details map[string]interface{}) *AppError {
+	e.Details = details
+	return e
+}
+
+func New(kind ErrorKind, message string) *AppError {
+	return &AppError{Kind: kind, Message: message}
+}
+
+func Wrap(kind ErrorKind, message string, cause error) *AppError {
+	return &AppError{Kind: kind, Message: message, Cause: cause}
+}
+
+func NewInvalidArgument(message string) *AppError {
+	return New(InvalidArgument, message)
+}
+
+func NewUnauthorized(message string) *AppError {
+	return New(Unauthorized, message)
+}
+
+func NewForbidden(message string) *AppError {
+	return New(Forbidden, message)
+}
+
+func NewNotFound(message string) *AppError {
+	return New(NotFound, message)
+}
+
+func NewConflict(message string) *AppError {
+	return New(Conflict, message)
+}
+
+func NewInternal(message string, cause error) *AppError {
+	return Wrap(Internal, message, cause)
+}
+
+// function to get the kind of error, errors which are not domain errors are internal
+func KindOf(err error) ErrorKind {
+	var appError *AppError
+	if errors.As(err, &appError) {
+		return appError.Kind
+	}
+	return Internal
+}
+
+// function to convert database driver error to domain error
+// domain errors are returned as is, so that the function can be used on any error returned by db calls
+func FromDbError(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	var appError *AppError
+	if errors.As(err, &appError) {
+		return err
+	}
+	var selectionError topology.ServerSelectionError
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return Wrap(NotFound, message+", not found", err)
+	case mongo.IsDuplicateKeyError(err):
+		return Wrap(Conflict, message+", already exists", err)
+	case mongo.IsNetworkError(err), mongo.IsTimeout(err), errors.As(err, &selectionError),
+		errors.Is(err, mongo.ErrClientDisconnected), errors.Is(err, context.DeadlineExceeded):
+		return Wrap(Unavailable, message+", database unavailable", err)
+	}
+	return Wrap(Internal, message, err)
+}
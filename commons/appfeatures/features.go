@@ -0,0 +1,54 @@
+package appfeatures
+
+import (
+	"maps"
+	"slices"
+	"sync/atomic"
+)
+
+// feature flags, disabled features are not served, e.g. to stop expensive routes without restart
+const (
+	FeatureUserExport = "user-export"
+	FeatureUserImport = "user-import"
+)
+
+// features are enabled unless disabled by configuration
+var defaults = map[string]bool{
+	FeatureUserExport: true,
+	FeatureUserImport: true,
+}
+
+var flags atomic.Pointer[map[string]bool]
+
+func init() {
+	Set(nil)
+}
+
+// function to get the default flags, the map is a copy
+func Defaults() map[string]bool {
+	return maps.Clone(defaults)
+}
+
+// function to get the names of the known features, sorted by name
+func Names() []string {
+	return slices.Sorted(maps.Keys(defaults))
+}
+
+func Known(name string) bool {
+	_, ok := defaults[name]
+	return ok
+}
+
+// function to set the flags, features which are not set keep their defaults
+func Set(values map[string]bool) {
+	current := Defaults()
+	for name, enabled := range values {
+		current[name] = enabled
+	}
+	flags.Store(&current)
+}
+
+// function to check if the feature is enabled, unknown features are disabled
+func Enabled(name string) bool {
+	return (*flags.Load())[name]
+}
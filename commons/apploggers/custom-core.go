@@ -7,34 +7,42 @@ import (
 )
 
 // wrapper for zap actual core to perform any required pre / post processing for message
+// levels are checked on write, because the caller of the entry is not known on check
 type customCore struct {
 	delegate zapcore.Core
+	filter   *levelFilter
+	redactor *redactor
 }
 
-func NewCustomCore(delegate zapcore.Core) zapcore.Core {
-	return &customCore{delegate: delegate}
+func NewCustomCore(delegate zapcore.Core, filter *levelFilter, redactor *redactor) zapcore.Core {
+	return &customCore{delegate: delegate, filter: filter, redactor: redactor}
 }
 
 func (z *customCore) Enabled(l zapcore.Level) bool {
-	return z.delegate.Enabled(l)
+	return z.filter.Enabled(l)
 }
 
 func (z *customCore) With(f []zapcore.Field) zapcore.Core {
-	newDelegate := z.delegate.With(f)
-	return NewCustomCore(newDelegate)
+	newDelegate := z.delegate.With(z.redactor.redactFields(f))
+	return NewCustomCore(newDelegate, z.filter, z.redactor)
 }
 
 func (z *customCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	res := z.delegate.Check(e, ce)
-	if res != nil {
-		// remove all newlines from message
-		res.Message = strings.ReplaceAll(e.Message, "\n", "<LINEBREAK>")
+	if z.Enabled(e.Level) {
+		return ce.AddCore(e, z)
 	}
-	return res
+	return ce
 }
 
 func (z *customCore) Write(e zapcore.Entry, f []zapcore.Field) error {
-	return z.delegate.Write(e, f)
+	if !z.filter.allows(e) {
+		return nil
+	}
+	// remove all newlines from message
+	e.Message = strings.ReplaceAll(e.Message, "\n", "<LINEBREAK>")
+	// mask or hash sensitive values, e.g. emails of users
+	e.Message = z.redactor.redactText(e.Message)
+	return z.delegate.Write(e, z.redactor.redactFields(f))
 }
 
 func (z *customCore) Sync() error {
@@ -0,0 +1,66 @@
+package apploggers
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// configuration of the log file
+// file is rotated when it reaches the max size or on the rotate interval, rotated files are removed after max age or max backups
+type FileConfig struct {
+	Path           string
+	MaxSizeMb      int
+	MaxAgeDays     int
+	MaxBackups     int
+	RotateInterval time.Duration
+	Compress       bool
+}
+
+// log file which is rotated by lumberjack, the interval rotation is stopped on close
+type fileOutput struct {
+	*lumberjack.Logger
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newFileOutput(config FileConfig) (*fileOutput, error) {
+	if len(config.Path) == 0 {
+		return nil, errors.New("log file path is required for file output")
+	}
+	file := &fileOutput{
+		Logger: &lumberjack.Logger{
+			Filename:   config.Path,
+			MaxSize:    config.MaxSizeMb,
+			MaxAge:     config.MaxAgeDays,
+			MaxBackups: config.MaxBackups,
+			LocalTime:  true,
+			Compress:   config.Compress,
+		},
+		stop: make(chan struct{}),
+	}
+	if config.RotateInterval > 0 {
+		go file.rotate(config.RotateInterval)
+	}
+	return file, nil
+}
+
+func (f *fileOutput) rotate(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = f.Rotate()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+func (f *fileOutput) Close() error {
+	f.stopOnce.Do(func() { close(f.stop) })
+	return f.Logger.Close()
+}
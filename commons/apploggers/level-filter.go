@@ -0,0 +1,107 @@
+package apploggers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// levels shared by all loggers, levels are changed at runtime by Configure and SetLevels
+var levels = &levelFilter{}
+
+// filter of entries by the current levels, levels are replaced atomically
+type levelFilter struct {
+	current atomic.Pointer[levelSet]
+}
+
+// levels of the loggers, packages can override the default level
+type levelSet struct {
+	level zapcore.Level
+	// packages are sorted by length, so that the most specific package is matched first
+	packages []packageLevel
+	// lowest of all levels, entries below are dropped on check
+	min zapcore.Level
+}
+
+type packageLevel struct {
+	name  string
+	level zapcore.Level
+}
+
+func newLevelSet(level zapcore.Level, packages map[string]zapcore.Level) *levelSet {
+	set := &levelSet{level: level, min: level}
+	for name, level := range packages {
+		set.packages = append(set.packages, packageLevel{name: name, level: level})
+		if level < set.min {
+			set.min = level
+		}
+	}
+	sort.Slice(set.packages, func(i, j int) bool {
+		if len(set.packages[i].name) != len(set.packages[j].name) {
+			return len(set.packages[i].name) > len(set.packages[j].name)
+		}
+		return set.packages[i].name < set.packages[j].name
+	})
+	return set
+}
+
+// function to parse package levels from comma separated "<package>=<level>"
+func parsePackageLevels(packageLevels string) (map[string]zapcore.Level, error) {
+	packages := map[string]zapcore.Level{}
+	for _, entry := range strings.Split(packageLevels, ",") {
+		if len(strings.TrimSpace(entry)) == 0 {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid package log level '%s', expected <package>=<level>", entry)
+		}
+		if err := addPackageLevel(packages, name, value); err != nil {
+			return nil, err
+		}
+	}
+	return packages, nil
+}
+
+func addPackageLevel(packages map[string]zapcore.Level, name string, value string) error {
+	name = strings.TrimSuffix(strings.TrimSpace(name), "/")
+	if len(name) == 0 {
+		return fmt.Errorf("invalid package log level '%s=%s', package is required", name, value)
+	}
+	level, err := zapcore.ParseLevel(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid log level of package '%s': %w", name, err)
+	}
+	packages[name] = level
+	return nil
+}
+
+func (f *levelFilter) Enabled(l zapcore.Level) bool {
+	return l >= f.current.Load().min
+}
+
+// function to check the level of the entry against the level of the package of its caller
+func (f *levelFilter) allows(e zapcore.Entry) bool {
+	set := f.current.Load()
+	if len(set.packages) > 0 && e.Caller.Defined {
+		name := callerPackage(e.Caller.Function)
+		for _, p := range set.packages {
+			if name == p.name || strings.HasPrefix(name, p.name+"/") {
+				return e.Level >= p.level
+			}
+		}
+	}
+	return e.Level >= set.level
+}
+
+// function to get the package of the function, e.g. "GolangCourse/internals/db" of "GolangCourse/internals/db.(*udbservice).GetUsers"
+func callerPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
@@ -38,6 +38,18 @@ func GetLoggerWithCorrelationid(ctx context.Context) *zap.SugaredLogger {
 	return nil
 }
 
+// function to add fields to the logger of the context, e.g. the principal of the request
+// context with the new logger is returned, so that the fields are logged by all layers
+func WithLoggerFields(ctx context.Context, fields ...zap.Field) (context.Context, *zap.SugaredLogger) {
+	logger, _ := ctx.Value(loggerKey{}).(*zap.Logger)
+	if logger == nil {
+		ctx, _ = NewLoggerWithCorrelationid(ctx, GetCorrelationId(ctx))
+		logger, _ = ctx.Value(loggerKey{}).(*zap.Logger)
+	}
+	logger = logger.With(fields...)
+	return context.WithValue(ctx, loggerKey{}, logger), logger.Sugar()
+}
+
 // function to return new logger instance
 func NewLogger() (context.Context, *zap.SugaredLogger) {
 	ctx := context.Background()
@@ -96,11 +108,14 @@ func GetCorrelationId(ctx context.Context) string {
 }
 
 // function to get the correlationid from the echo context
+// new logger is stored in the echo context, so that the same correlationid is used for the whole request
 func GetLoggerFromEcho(c echo.Context) (context.Context, *zap.SugaredLogger) {
 	if valRaw := c.Get("context"); valRaw != nil {
 		if ctx, ok := valRaw.(context.Context); ok {
 			return ctx, GetLoggerWithCorrelationid(ctx)
 		}
 	}
-	return NewLoggerWithCorrelationid(context.Background(), "")
+	ctx, logger := NewLoggerWithCorrelationid(context.Background(), "")
+	c.Set("context", ctx)
+	return ctx, logger
 }
@@ -0,0 +1,207 @@
+package apploggers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// sensitive values are replaced by "***"
+	RedactionMask = "mask"
+	// sensitive values are replaced by a hash, so that entries of the same value can be correlated
+	RedactionHash = "hash"
+	// sensitive values are logged, only meant for local development
+	RedactionOff = "off"
+
+	// min length of the key of hash mode
+	MinRedactionHashKeyLength = 16
+
+	redactedValue = "***"
+)
+
+// configuration of the redaction of sensitive values in messages and fields of log entries
+type RedactionConfig struct {
+	Mode string
+	// names of sensitive fields in addition to the registered fields
+	Fields []string
+	// key of the hash, required by hash mode, as hashes without key are reversed by hashing guessed values
+	HashKey string
+}
+
+var (
+	sensitiveFieldsMux sync.RWMutex
+	// json and log field names of sensitive values
+	sensitiveFields = map[string]bool{
+		"name": true, "email": true, "password": true, "current_password": true, "otp": true, "code": true,
+		"secret": true, "uri": true, "key": true, "access_token": true, "refresh_token": true, "recovery_codes": true,
+		"authorization": true, "token": true,
+	}
+	// patterns of sensitive values, which are redacted in messages and string fields of any name
+	// credentials of authorization headers are matched first, so that the redacted credentials are not matched again
+	sensitivePatterns = []*regexp.Regexp{
+		// credentials of authorization headers
+		regexp.MustCompile(`(?i)\b(bearer|apikey|basic) +[A-Za-z0-9._~+/=-]+`),
+		// json web tokens
+		regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		// api keys and refresh tokens
+		regexp.MustCompile(`\b(uk|rt)_[0-9a-f_]{16,}\b`),
+		// emails
+		regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	}
+)
+
+// function to register names of sensitive fields, values of the fields are redacted in json of messages and in log fields
+func RegisterSensitiveFields(names ...string) {
+	sensitiveFieldsMux.Lock()
+	defer sensitiveFieldsMux.Unlock()
+	for _, name := range names {
+		sensitiveFields[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+}
+
+type redactor struct {
+	mode    string
+	hashKey []byte
+	fields  map[string]bool
+	// matches json string and string array values of the sensitive fields
+	jsonFields *regexp.Regexp
+}
+
+func newRedactor(config RedactionConfig) (*redactor, error) {
+	mode := strings.ToLower(strings.TrimSpace(config.Mode))
+	if mode == "" {
+		mode = RedactionMask
+	}
+	if mode != RedactionMask && mode != RedactionHash && mode != RedactionOff {
+		return nil, fmt.Errorf("invalid log redaction '%s', expected mask, hash or off", config.Mode)
+	}
+	if mode == RedactionHash && len(config.HashKey) < MinRedactionHashKeyLength {
+		return nil, fmt.Errorf("log redaction hash requires a hash key of at least %d bytes", MinRedactionHashKeyLength)
+	}
+	r := &redactor{mode: mode, hashKey: []byte(config.HashKey), fields: map[string]bool{}}
+	sensitiveFieldsMux.RLock()
+	for name := range sensitiveFields {
+		r.fields[name] = true
+	}
+	sensitiveFieldsMux.RUnlock()
+	for _, name := range config.Fields {
+		if name = strings.ToLower(strings.TrimSpace(name)); len(name) > 0 {
+			r.fields[name] = true
+		}
+	}
+	names := make([]string, 0, len(r.fields))
+	for name := range r.fields {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Strings(names)
+	r.jsonFields = regexp.MustCompile(`(?i)"(` + strings.Join(names, "|") + `)"\s*:\s*("(?:[^"\\]|\\.)*"|\[(?:\s*"(?:[^"\\]|\\.)*"\s*,?)*\])`)
+	return r, nil
+}
+
+func (r *redactor) enabled() bool {
+	return r != nil && r.mode != RedactionOff
+}
+
+// function to replace the sensitive value according to the mode
+func (r *redactor) redact(value string) string {
+	if r.mode != RedactionHash {
+		return redactedValue
+	}
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(value))
+	return "sha256:" + hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// function to redact values of sensitive json fields and sensitive patterns of the text
+func (r *redactor) redactText(text string) string {
+	if !r.enabled() {
+		return text
+	}
+	text = r.replaceSubmatch(r.jsonFields, text, 2, func(value string) string {
+		return `"` + r.redact(value) + `"`
+	})
+	for _, pattern := range sensitivePatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(value string) string {
+			// scheme of authorization headers is kept
+			if scheme, credentials, found := strings.Cut(value, " "); found {
+				return scheme + " " + r.redact(strings.TrimSpace(credentials))
+			}
+			return r.redact(value)
+		})
+	}
+	return text
+}
+
+func (r *redactor) replaceSubmatch(pattern *regexp.Regexp, text string, group int, replace func(string) string) string {
+	matches := pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[2*group], match[2*group+1]
+		builder.WriteString(text[last:start])
+		builder.WriteString(replace(text[start:end]))
+		last = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// function to redact fields of sensitive names and sensitive patterns of string, error and object fields
+func (r *redactor) redactFields(fields []zapcore.Field) []zapcore.Field {
+	if !r.enabled() || len(fields) == 0 {
+		return fields
+	}
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = r.redactField(field)
+	}
+	return redacted
+}
+
+func (r *redactor) redactField(field zapcore.Field) zapcore.Field {
+	sensitive := r.fields[strings.ToLower(field.Key)]
+	switch field.Type {
+	case zapcore.StringType:
+		if sensitive {
+			return zap.String(field.Key, r.redact(field.String))
+		}
+		return zap.String(field.Key, r.redactText(field.String))
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok && err != nil {
+			return zap.String(field.Key, r.redactText(err.Error()))
+		}
+	case zapcore.StringerType:
+		if stringer, ok := field.Interface.(fmt.Stringer); ok && stringer != nil {
+			if sensitive {
+				return zap.String(field.Key, r.redact(stringer.String()))
+			}
+			return zap.String(field.Key, r.redactText(stringer.String()))
+		}
+	case zapcore.ReflectType, zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType:
+		if sensitive {
+			return zap.String(field.Key, redactedValue)
+		}
+		// objects are logged as their json, so that their sensitive fields are redacted
+		if payload, err := json.Marshal(field.Interface); err == nil {
+			return zap.Reflect(field.Key, json.RawMessage(r.redactText(string(payload))))
+		}
+	case zapcore.ByteStringType, zapcore.BinaryType:
+		if sensitive {
+			return zap.String(field.Key, redactedValue)
+		}
+	}
+	return field
+}
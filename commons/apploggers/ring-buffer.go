@@ -0,0 +1,172 @@
+package apploggers
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// entry of the recent logs
+type LogEntry struct {
+	Time          time.Time
+	Level         zapcore.Level
+	Caller        string
+	Message       string
+	CorrelationId string
+	Fields        map[string]interface{}
+}
+
+// query of the recent logs, empty values match all entries
+type LogQuery struct {
+	CorrelationId string
+	MinLevel      zapcore.Level
+	Since         time.Time
+	// max number of entries, the newest entries are returned
+	Limit int
+}
+
+// bounded buffer of the last entries, the oldest entry is replaced when the buffer is full
+// entries are indexed by correlation id, so that the trail of a request is found without scanning the buffer
+type ringBuffer struct {
+	mux     sync.RWMutex
+	entries []*LogEntry
+	// sequence number of the next entry, the slot of an entry is its sequence number modulo the size
+	next  uint64
+	index map[string][]uint64
+}
+
+// recent logs of all loggers, nil when disabled
+var recentLogs *ringBuffer
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{entries: make([]*LogEntry, size), index: map[string][]uint64{}}
+}
+
+func (r *ringBuffer) add(entry *LogEntry) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	size := uint64(len(r.entries))
+	slot := r.next % size
+	// sequence numbers of a correlation id are ascending, so the replaced entry is the first of its index
+	if replaced := r.entries[slot]; replaced != nil && len(replaced.CorrelationId) > 0 {
+		if sequences := r.index[replaced.CorrelationId]; len(sequences) > 1 {
+			r.index[replaced.CorrelationId] = sequences[1:]
+		} else {
+			delete(r.index, replaced.CorrelationId)
+		}
+	}
+	r.entries[slot] = entry
+	if len(entry.CorrelationId) > 0 {
+		r.index[entry.CorrelationId] = append(r.index[entry.CorrelationId], r.next)
+	}
+	r.next++
+}
+
+// function to get entries of the query, oldest first
+func (r *ringBuffer) find(query *LogQuery) []*LogEntry {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	size := uint64(len(r.entries))
+	var sequences []uint64
+	if len(query.CorrelationId) > 0 {
+		sequences = r.index[query.CorrelationId]
+	} else {
+		first := uint64(0)
+		if r.next > size {
+			first = r.next - size
+		}
+		for sequence := first; sequence < r.next; sequence++ {
+			sequences = append(sequences, sequence)
+		}
+	}
+	var found []*LogEntry
+	for _, sequence := range sequences {
+		entry := r.entries[sequence%size]
+		if entry.Level < query.MinLevel || entry.Time.Before(query.Since) {
+			continue
+		}
+		found = append(found, entry)
+	}
+	if query.Limit > 0 && len(found) > query.Limit {
+		found = found[len(found)-query.Limit:]
+	}
+	return found
+}
+
+// function to find recent entries of all loggers, nil is returned when the recent logs are disabled
+func FindRecentLogs(query *LogQuery) []*LogEntry {
+	buffer := recentLogsBuffer()
+	if buffer == nil {
+		return nil
+	}
+	return buffer.find(query)
+}
+
+// function to check if recent logs are kept
+func RecentLogsEnabled() bool {
+	return recentLogsBuffer() != nil
+}
+
+func recentLogsBuffer() *ringBuffer {
+	configureMux.Lock()
+	defer configureMux.Unlock()
+	return recentLogs
+}
+
+// core which adds the entries to the ring buffer, fields are encoded on write
+type ringCore struct {
+	zapcore.LevelEnabler
+	buffer        *ringBuffer
+	fields        []zapcore.Field
+	correlationId string
+}
+
+func newRingCore(buffer *ringBuffer, enabler zapcore.LevelEnabler) zapcore.Core {
+	return &ringCore{LevelEnabler: enabler, buffer: buffer}
+}
+
+func (r *ringCore) With(f []zapcore.Field) zapcore.Core {
+	clone := *r
+	clone.fields = append(append(make([]zapcore.Field, 0, len(r.fields)+len(f)), r.fields...), f...)
+	for _, field := range f {
+		if field.Key == "correlationid" && field.Type == zapcore.StringType {
+			clone.correlationId = field.String
+		}
+	}
+	return &clone
+}
+
+func (r *ringCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if r.Enabled(e.Level) {
+		return ce.AddCore(e, r)
+	}
+	return ce
+}
+
+func (r *ringCore) Write(e zapcore.Entry, f []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range r.fields {
+		field.AddTo(encoder)
+	}
+	for _, field := range f {
+		field.AddTo(encoder)
+	}
+	delete(encoder.Fields, "correlationid")
+	entry := &LogEntry{
+		Time:          e.Time,
+		Level:         e.Level,
+		Message:       e.Message,
+		CorrelationId: r.correlationId,
+		Fields:        encoder.Fields,
+	}
+	if e.Caller.Defined {
+		entry.Caller = e.Caller.TrimmedPath()
+	}
+	r.buffer.add(entry)
+	return nil
+}
+
+func (r *ringCore) Sync() error {
+	return nil
+}
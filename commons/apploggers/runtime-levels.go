@@ -0,0 +1,122 @@
+package apploggers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// current levels of the loggers
+type Levels struct {
+	Level string
+	// levels of packages and their sub packages
+	Packages map[string]string
+	// time at which changed levels are reverted, nil when the levels are kept
+	RevertAt *time.Time
+}
+
+var (
+	revertMux sync.Mutex
+	// levels which are restored when the timer fires
+	revertTo    *levelSet
+	revertAt    time.Time
+	revertTimer *time.Timer
+)
+
+// function to get the current levels of the loggers
+func GetLevels() *Levels {
+	revertMux.Lock()
+	defer revertMux.Unlock()
+	set := levels.current.Load()
+	current := &Levels{Level: set.level.String(), Packages: map[string]string{}}
+	for _, p := range set.packages {
+		current.Packages[p.name] = p.level.String()
+	}
+	if revertTimer != nil {
+		at := revertAt
+		current.RevertAt = &at
+	}
+	return current
+}
+
+// function to change the levels of all loggers at runtime
+// empty level keeps the current level and nil packages keep the current package levels
+// levels are reverted after ttl to the levels before the first change, ttl 0 keeps the levels
+func SetLevels(level string, packages map[string]string, ttl time.Duration) (*Levels, error) {
+	if ttl < 0 {
+		return nil, fmt.Errorf("invalid ttl %s, must not be negative", ttl)
+	}
+	if err := changeLevels(level, packages, ttl); err != nil {
+		return nil, err
+	}
+	return GetLevels(), nil
+}
+
+// function to change the current levels, levels are read, changed and swapped under the lock
+// so that concurrent changes and reverts are not lost
+func changeLevels(level string, packages map[string]string, ttl time.Duration) error {
+	revertMux.Lock()
+	defer revertMux.Unlock()
+	current := levels.current.Load()
+	nextLevel := current.level
+	if len(strings.TrimSpace(level)) > 0 {
+		parsed, err := zapcore.ParseLevel(strings.TrimSpace(level))
+		if err != nil {
+			return fmt.Errorf("invalid log level: %w", err)
+		}
+		nextLevel = parsed
+	}
+	nextPackages := map[string]zapcore.Level{}
+	if packages == nil {
+		for _, p := range current.packages {
+			nextPackages[p.name] = p.level
+		}
+	}
+	for name, value := range packages {
+		if err := addPackageLevel(nextPackages, name, value); err != nil {
+			return err
+		}
+	}
+
+	previous := levels.current.Swap(newLevelSet(nextLevel, nextPackages))
+	if revertTimer != nil {
+		revertTimer.Stop()
+		revertTimer = nil
+		// levels of the first change are restored, not the levels of the previous temporary change
+		previous = revertTo
+	}
+	revertTo = nil
+	if ttl > 0 {
+		revertTo = previous
+		revertAt = time.Now().Add(ttl)
+		var timer *time.Timer
+		timer = time.AfterFunc(ttl, func() {
+			revertMux.Lock()
+			defer revertMux.Unlock()
+			// timer was replaced by a later change
+			if revertTimer != timer {
+				return
+			}
+			levels.current.Store(revertTo)
+			revertTo = nil
+			revertTimer = nil
+		})
+		revertTimer = timer
+	}
+	return nil
+}
+
+// function to set the configured levels, pending reverts are cancelled
+func setConfiguredLevels(set *levelSet) {
+	revertMux.Lock()
+	defer revertMux.Unlock()
+	if revertTimer != nil {
+		revertTimer.Stop()
+		revertTimer = nil
+		revertTo = nil
+	}
+	levels.current.Store(set)
+}
@@ -0,0 +1,21 @@
+//go:build windows || plan9
+
+package apploggers
+
+import (
+	"errors"
+	"io"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// configuration of the syslog output, empty network and address write to the local syslog daemon
+type SyslogConfig struct {
+	Network string
+	Address string
+	Tag     string
+}
+
+func newSyslogOutput(config SyslogConfig, encoder zapcore.Encoder, enabler zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
+	return nil, nil, errors.New("syslog output is not supported on this platform")
+}
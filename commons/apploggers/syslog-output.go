@@ -0,0 +1,73 @@
+//go:build !windows && !plan9
+
+package apploggers
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// configuration of the syslog output, empty network and address write to the local syslog daemon
+type SyslogConfig struct {
+	Network string
+	Address string
+	Tag     string
+}
+
+// core which writes entries with the syslog severity of their level
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *syslog.Writer
+}
+
+func newSyslogOutput(config SyslogConfig, encoder zapcore.Encoder, enabler zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
+	writer, err := syslog.Dial(config.Network, config.Address, syslog.LOG_INFO|syslog.LOG_USER, config.Tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &syslogCore{LevelEnabler: enabler, encoder: encoder, writer: writer}, writer, nil
+}
+
+func (s *syslogCore) With(f []zapcore.Field) zapcore.Core {
+	encoder := s.encoder.Clone()
+	for _, field := range f {
+		field.AddTo(encoder)
+	}
+	return &syslogCore{LevelEnabler: s.LevelEnabler, encoder: encoder, writer: s.writer}
+}
+
+func (s *syslogCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if s.Enabled(e.Level) {
+		return ce.AddCore(e, s)
+	}
+	return ce
+}
+
+func (s *syslogCore) Write(e zapcore.Entry, f []zapcore.Field) error {
+	buffer, err := s.encoder.EncodeEntry(e, f)
+	if err != nil {
+		return err
+	}
+	message := strings.TrimSuffix(buffer.String(), "\n")
+	buffer.Free()
+	switch e.Level {
+	case zapcore.DebugLevel:
+		return s.writer.Debug(message)
+	case zapcore.InfoLevel:
+		return s.writer.Info(message)
+	case zapcore.WarnLevel:
+		return s.writer.Warning(message)
+	case zapcore.ErrorLevel:
+		return s.writer.Err(message)
+	default:
+		return s.writer.Crit(message)
+	}
+}
+
+func (s *syslogCore) Sync() error {
+	return nil
+}
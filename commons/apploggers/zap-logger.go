@@ -1,26 +1,231 @@
 package apploggers
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// configuration of the logger
+// outputs are "stdout", "stderr", "file" and "syslog", stdout is used when no output is configured
+type Config struct {
+	Level     string
+	Format    string
+	Outputs   []string
+	File      FileConfig
+	Syslog    SyslogConfig
+	Redaction RedactionConfig
+	// comma separated "<package>=<level>", e.g. "GolangCourse/internals/db=warn", sub packages share the level
+	PackageLevels string
+	// number of recent entries which are kept in memory, 0 disables the recent logs
+	RecentLogsSize int
+}
+
+// outputs of the logger, shared by all loggers
+// loggers are bound to the outputs on use, so that loggers created before Configure use the new outputs
+type loggerOutputs struct {
+	core     zapcore.Core
+	redactor *redactor
+	closers  []io.Closer
+}
+
+var (
+	outputs      atomic.Pointer[loggerOutputs]
+	configureMux sync.Mutex
+)
+
+func init() {
+	// default logger writes all levels to stdout
+	levels.current.Store(newLevelSet(zapcore.DebugLevel, nil))
+	redactor, _ := newRedactor(RedactionConfig{Mode: RedactionMask})
+	core := zapcore.NewCore(getEncoder("console"), zapcore.AddSync(os.Stdout), levels)
+	outputs.Store(&loggerOutputs{core: NewCustomCore(core, levels, redactor), redactor: redactor})
+}
+
 // function to create new zap logger
-// logger will write to stdout only for now. hence commenting write to file
 func NewZapLogger() *zap.Logger {
-	encoder := getEncoder()
-	core := zapcore.NewTee(
-		NewCustomCore(zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), zapcore.DebugLevel)),
-	)
-	allLogger := zap.New(core, zap.AddCaller())
-	return allLogger
+	return zap.New(&dynamicCore{}, zap.AddCaller())
+}
+
+// function to configure level, format and outputs of all loggers
+// outputs of the previous configuration are closed and levels changed at runtime are replaced
+func Configure(config *Config) error {
+	prepared, err := Prepare(config)
+	if err != nil {
+		return err
+	}
+	prepared.Apply()
+	return nil
+}
+
+// configuration of the loggers which is validated and has its outputs opened, but is not used yet
+// it is either applied or discarded, so that a configuration is only applied when nothing else can fail
+type Prepared struct {
+	next           *loggerOutputs
+	cores          []zapcore.Core
+	levels         *levelSet
+	recentLogsSize int
+}
+
+// function to validate the configuration and open its outputs, the current configuration is not changed
+func Prepare(config *Config) (*Prepared, error) {
+	level := zapcore.InfoLevel
+	if len(strings.TrimSpace(config.Level)) > 0 {
+		parsed, err := zapcore.ParseLevel(strings.TrimSpace(config.Level))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level: %w", err)
+		}
+		level = parsed
+	}
+	packages, err := parsePackageLevels(config.PackageLevels)
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := newRedactor(config.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	format := strings.ToLower(strings.TrimSpace(config.Format))
+	if format != "" && format != "console" && format != "json" {
+		return nil, fmt.Errorf("invalid log format '%s', expected console or json", config.Format)
+	}
+	names := config.Outputs
+	if len(names) == 0 {
+		names = []string{"stdout"}
+	}
+
+	next := &loggerOutputs{redactor: redactor}
+	var cores []zapcore.Core
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "stdout":
+			cores = append(cores, zapcore.NewCore(getEncoder(format), zapcore.Lock(os.Stdout), levels))
+		case "stderr":
+			cores = append(cores, zapcore.NewCore(getEncoder(format), zapcore.Lock(os.Stderr), levels))
+		case "file":
+			file, ferr := newFileOutput(config.File)
+			if ferr != nil {
+				next.close()
+				return nil, ferr
+			}
+			next.closers = append(next.closers, file)
+			cores = append(cores, zapcore.NewCore(getEncoder(format), zapcore.AddSync(file), levels))
+		case "syslog":
+			core, closer, serr := newSyslogOutput(config.Syslog, getEncoder(format), levels)
+			if serr != nil {
+				next.close()
+				return nil, serr
+			}
+			next.closers = append(next.closers, closer)
+			cores = append(cores, core)
+		default:
+			next.close()
+			return nil, fmt.Errorf("invalid log output '%s', expected stdout, stderr, file or syslog", name)
+		}
+	}
+	return &Prepared{next: next, cores: cores, levels: newLevelSet(level, packages), recentLogsSize: config.RecentLogsSize}, nil
+}
+
+// function to use the prepared configuration for all loggers, outputs of the previous configuration are closed
+func (p *Prepared) Apply() {
+	configureMux.Lock()
+	defer configureMux.Unlock()
+	cores := p.cores
+	// recent logs are kept when the size is not changed
+	if p.recentLogsSize <= 0 {
+		recentLogs = nil
+	} else if recentLogs == nil || len(recentLogs.entries) != p.recentLogsSize {
+		recentLogs = newRingBuffer(p.recentLogsSize)
+	}
+	if recentLogs != nil {
+		cores = append(cores, newRingCore(recentLogs, levels))
+	}
+	p.next.core = NewCustomCore(zapcore.NewTee(cores...), levels, p.next.redactor)
+	setConfiguredLevels(p.levels)
+	previous := outputs.Swap(p.next)
+	_ = previous.core.Sync()
+	previous.close()
+}
+
+// function to close the outputs of the prepared configuration, when it is not applied
+func (p *Prepared) Discard() {
+	p.next.close()
+}
+
+// function to redact sensitive values of the text with the configured redaction, e.g. of errors which are sent elsewhere
+func Redact(text string) string {
+	return outputs.Load().redactor.redactText(text)
 }
 
-func getEncoder() zapcore.Encoder {
+// function to flush buffered entries of all outputs, e.g. before exit
+func Sync() error {
+	return outputs.Load().core.Sync()
+}
+
+func (o *loggerOutputs) close() {
+	for _, closer := range o.closers {
+		_ = closer.Close()
+	}
+}
+
+func getEncoder(format string) zapcore.Encoder {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	if format == "json" {
+		return zapcore.NewJSONEncoder(encoderConfig)
+	}
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	return zapcore.NewConsoleEncoder(encoderConfig)
 }
+
+// core which delegates to the current outputs, fields of the logger are added again after Configure
+type dynamicCore struct {
+	fields []zapcore.Field
+	bound  atomic.Pointer[boundCore]
+}
+
+type boundCore struct {
+	outputs *loggerOutputs
+	core    zapcore.Core
+}
+
+func (d *dynamicCore) current() zapcore.Core {
+	current := outputs.Load()
+	if bound := d.bound.Load(); bound != nil && bound.outputs == current {
+		return bound.core
+	}
+	core := current.core
+	if len(d.fields) > 0 {
+		core = core.With(d.fields)
+	}
+	d.bound.Store(&boundCore{outputs: current, core: core})
+	return core
+}
+
+func (d *dynamicCore) Enabled(l zapcore.Level) bool {
+	return d.current().Enabled(l)
+}
+
+func (d *dynamicCore) With(f []zapcore.Field) zapcore.Core {
+	fields := make([]zapcore.Field, 0, len(d.fields)+len(f))
+	fields = append(append(fields, d.fields...), f...)
+	return &dynamicCore{fields: fields}
+}
+
+func (d *dynamicCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return d.current().Check(e, ce)
+}
+
+func (d *dynamicCore) Write(e zapcore.Entry, f []zapcore.Field) error {
+	return d.current().Write(e, f)
+}
+
+func (d *dynamicCore) Sync() error {
+	return d.current().Sync()
+}
@@ -0,0 +1,52 @@
+package appmetrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	mongoConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongodb_pool_connections",
+		Help: "Number of open connections of the mongo connection pool by server.",
+	}, []string{"address"})
+	mongoConnectionsInUse = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongodb_pool_connections_in_use",
+		Help: "Number of checked out connections of the mongo connection pool by server.",
+	}, []string{"address"})
+	mongoCheckOuts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongodb_pool_checkouts_total",
+		Help: "Number of connection check outs of the mongo connection pool by server and result.",
+	}, []string{"address", "result"})
+	mongoPoolCleared = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongodb_pool_cleared_total",
+		Help: "Number of times the mongo connection pool was cleared by server, e.g. after network errors.",
+	}, []string{"address"})
+)
+
+func init() {
+	registry.MustRegister(mongoConnections, mongoConnectionsInUse, mongoCheckOuts, mongoPoolCleared)
+}
+
+// function to get the monitor of the mongo connection pool, which records the pool metrics
+func NewPoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				mongoConnections.WithLabelValues(e.Address).Inc()
+			case event.ConnectionClosed:
+				mongoConnections.WithLabelValues(e.Address).Dec()
+			case event.GetSucceeded:
+				mongoConnectionsInUse.WithLabelValues(e.Address).Inc()
+				mongoCheckOuts.WithLabelValues(e.Address, "succeeded").Inc()
+			case event.GetFailed:
+				mongoCheckOuts.WithLabelValues(e.Address, "failed").Inc()
+			case event.ConnectionReturned:
+				mongoConnectionsInUse.WithLabelValues(e.Address).Dec()
+			case event.PoolCleared:
+				mongoPoolCleared.WithLabelValues(e.Address).Inc()
+			}
+		},
+	}
+}
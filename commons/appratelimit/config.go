@@ -0,0 +1,64 @@
+package appratelimit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rate limits of routes, routes are keyed by method and path of the route e.g. "GET /users/:id"
+// routes without own rule share the default rule
+// client ip rule limits all requests of a client ip before authentication, so that guessing of credentials is limited
+type Config struct {
+	Default  *Rule
+	ClientIp *Rule
+	Routes   map[string]*Rule
+}
+
+// function to parse route rules from comma separated "<method> <path>=<limit>/<period>"
+// e.g. "GET /users=10/m,POST /auth/login=5/m"
+func ParseConfig(defaultRule string, routeRules string, clientIpRule string) (*Config, error) {
+	config := &Config{Routes: map[string]*Rule{}}
+	if len(strings.TrimSpace(defaultRule)) > 0 {
+		rule, err := ParseRule(defaultRule)
+		if err != nil {
+			return nil, err
+		}
+		config.Default = rule
+	}
+	if len(strings.TrimSpace(clientIpRule)) > 0 {
+		rule, err := ParseRule(clientIpRule)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientIp = rule
+	}
+	for _, entry := range strings.Split(routeRules, ",") {
+		if len(strings.TrimSpace(entry)) == 0 {
+			continue
+		}
+		route, value, found := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !found || !hasPath {
+			return nil, fmt.Errorf("invalid route rate limit '%s', expected <method> <path>=<limit>/<period>", entry)
+		}
+		rule, err := ParseRule(value)
+		if err != nil {
+			return nil, err
+		}
+		config.Routes[RouteKey(method, path)] = rule
+	}
+	return config, nil
+}
+
+// function to get rule of the route and the name of its bucket, nil is returned for unlimited routes
+func (c *Config) RuleFor(method string, path string) (string, *Rule) {
+	key := RouteKey(method, path)
+	if rule, ok := c.Routes[key]; ok {
+		return key, rule
+	}
+	return "default", c.Default
+}
+
+func RouteKey(method string, path string) string {
+	return strings.ToUpper(strings.TrimSpace(method)) + " " + strings.TrimSpace(path)
+}
@@ -0,0 +1,59 @@
+package appratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig("100/m", " get /users=10/m, POST /auth/login=5/m ,", "50/s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Default.Limit != 100 || config.ClientIp.Limit != 50 || config.ClientIp.Period != time.Second || len(config.Routes) != 2 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	tests := []struct {
+		method string
+		path   string
+		bucket string
+		limit  int
+	}{
+		{"GET", "/users", "GET /users", 10},
+		{"post", "/auth/login", "POST /auth/login", 5},
+		{"POST", "/users", "default", 100},
+	}
+	for _, test := range tests {
+		bucket, rule := config.RuleFor(test.method, test.path)
+		if bucket != test.bucket || rule.Limit != test.limit {
+			t.Errorf("%s %s: expected bucket %s with limit %d, got %s with %+v", test.method, test.path, test.bucket, test.limit, bucket, rule)
+		}
+	}
+}
+
+func TestParseConfigWithoutRules(t *testing.T) {
+	config, err := ParseConfig("", "", " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bucket, rule := config.RuleFor("GET", "/users"); bucket != "default" || rule != nil || config.ClientIp != nil {
+		t.Fatalf("routes must be unlimited without rules, got %s %+v", bucket, rule)
+	}
+}
+
+func TestParseConfigRejectsInvalidRules(t *testing.T) {
+	tests := map[string][3]string{
+		"default":         {"100", "", ""},
+		"client ip":       {"", "", "0/s"},
+		"route rule":      {"", "GET /users=10", ""},
+		"route path":      {"", "GET=10/m", ""},
+		"route separator": {"", "GET /users 10/m", ""},
+	}
+	for name, rules := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseConfig(rules[0], rules[1], rules[2]); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package appratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idle buckets are removed after this interval, they are full again and equal to new buckets
+const cleanupInterval = time.Minute
+
+// limit of requests per period, e.g. "100/m"
+// clients can use the whole limit at once, tokens are refilled evenly over the period
+type Rule struct {
+	Limit  int
+	Period time.Duration
+}
+
+// function to parse rule from "<limit>/<period>", period is s, m, h or a duration e.g. "10/30s"
+func ParseRule(value string) (*Rule, error) {
+	limitValue, periodValue, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return nil, fmt.Errorf("invalid rate limit '%s', expected <limit>/<period>", value)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(limitValue))
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("invalid rate limit '%s', limit must be a positive number", value)
+	}
+	periodValue = strings.TrimSpace(periodValue)
+	switch periodValue {
+	case "s", "m", "h":
+		periodValue = "1" + periodValue
+	}
+	period, err := time.ParseDuration(periodValue)
+	if err != nil || period <= 0 {
+		return nil, fmt.Errorf("invalid rate limit '%s', period must be a positive duration", value)
+	}
+	return &Rule{Limit: limit, Period: period}, nil
+}
+
+// function to get the policy of the rule, as in RateLimit-Policy header e.g. "100;w=60"
+func (r *Rule) Policy() string {
+	return fmt.Sprintf("%d;w=%d", r.Limit, int64(math.Ceil(r.Period.Seconds())))
+}
+
+func (r *Rule) rate() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// result of a request, durations are rounded up to seconds by callers
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rule   *Rule
+}
+
+// in memory token bucket limiter, buckets are kept per key
+// limits are per instance, every instance of the service counts its own requests
+type Limiter struct {
+	mutex       sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// function to take a token of the bucket of the key
+func (l *Limiter) Allow(key string, rule *Rule) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	l.cleanup(now)
+
+	current, ok := l.buckets[key]
+	if !ok || current.rule != rule {
+		current = &bucket{tokens: float64(rule.Limit), last: now, rule: rule}
+		l.buckets[key] = current
+	}
+	rate := rule.rate()
+	current.tokens = math.Min(float64(rule.Limit), current.tokens+now.Sub(current.last).Seconds()*rate)
+	current.last = now
+
+	result := Result{Allowed: current.tokens >= 1}
+	if result.Allowed {
+		current.tokens--
+	} else {
+		result.RetryAfter = secondsDuration((1 - current.tokens) / rate)
+	}
+	result.Remaining = int(current.tokens)
+	result.Reset = secondsDuration((float64(rule.Limit) - current.tokens) / rate)
+	return result
+}
+
+// function to remove buckets which are full again, as they are equal to new buckets
+func (l *Limiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < cleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+	for key, current := range l.buckets {
+		if now.Sub(current.last) >= current.rule.Period {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,105 @@
+package appratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// function to create limiter with a clock which is moved by tests
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewLimiter()
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		value  string
+		limit  int
+		period time.Duration
+		valid  bool
+	}{
+		{"100/m", 100, time.Minute, true},
+		{" 5 / s ", 5, time.Second, true},
+		{"10/30s", 10, 30 * time.Second, true},
+		{"1/h", 1, time.Hour, true},
+		{"100", 0, 0, false},
+		{"0/m", 0, 0, false},
+		{"-1/m", 0, 0, false},
+		{"ten/m", 0, 0, false},
+		{"10/d", 0, 0, false},
+		{"10/-1s", 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			rule, err := ParseRule(test.value)
+			if !test.valid {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", rule)
+				}
+				return
+			}
+			if err != nil || rule.Limit != test.limit || rule.Period != test.period {
+				t.Fatalf("unexpected rule %+v: %v", rule, err)
+			}
+		})
+	}
+}
+
+func TestRulePolicy(t *testing.T) {
+	if policy := (&Rule{Limit: 100, Period: time.Minute}).Policy(); policy != "100;w=60" {
+		t.Fatalf("unexpected policy %s", policy)
+	}
+	if policy := (&Rule{Limit: 5, Period: 1500 * time.Millisecond}).Policy(); policy != "5;w=2" {
+		t.Fatalf("windows must be rounded up, got %s", policy)
+	}
+}
+
+func TestLimiterAllowsBurstAndRefills(t *testing.T) {
+	limiter, now := newTestLimiter()
+	rule := &Rule{Limit: 3, Period: 3 * time.Second}
+	for remaining := 2; remaining >= 0; remaining-- {
+		result := limiter.Allow("client", rule)
+		if !result.Allowed || result.Remaining != remaining {
+			t.Fatalf("expected allowed with %d remaining, got %+v", remaining, result)
+		}
+	}
+	result := limiter.Allow("client", rule)
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Fatalf("empty bucket must be limited until a token is refilled, got %+v", result)
+	}
+
+	// other keys have their own buckets
+	if result := limiter.Allow("other", rule); !result.Allowed {
+		t.Fatalf("other keys must not be limited, got %+v", result)
+	}
+
+	*now = now.Add(time.Second)
+	if result := limiter.Allow("client", rule); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("a token must be refilled after a second, got %+v", result)
+	}
+	*now = now.Add(time.Hour)
+	if result := limiter.Allow("client", rule); !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("buckets must not exceed the limit, got %+v", result)
+	}
+}
+
+func TestLimiterResetsBucketOfChangedRule(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	limiter.Allow("client", &Rule{Limit: 1, Period: time.Minute})
+	if result := limiter.Allow("client", &Rule{Limit: 2, Period: time.Minute}); !result.Allowed || result.Remaining != 1 {
+		t.Fatalf("bucket of the changed rule must be full, got %+v", result)
+	}
+}
+
+func TestLimiterRemovesFullBuckets(t *testing.T) {
+	limiter, now := newTestLimiter()
+	rule := &Rule{Limit: 1, Period: time.Second}
+	limiter.Allow("client", rule)
+	*now = now.Add(cleanupInterval)
+	limiter.Allow("other", rule)
+	if _, ok := limiter.buckets["client"]; ok || len(limiter.buckets) != 1 {
+		t.Fatalf("idle buckets must be removed, got %v", limiter.buckets)
+	}
+}
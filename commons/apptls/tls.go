@@ -0,0 +1,150 @@
+package apptls
+
+import (
+	"GolangCourse/commons/apploggers"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// client certificate modes
+const (
+	ClientAuthNone = "none"
+	// certificates are verified when the client sends one, e.g. when only internal callers have certificates
+	ClientAuthRequest = "request"
+	ClientAuthRequire = "require"
+)
+
+// configuration of the https server, files are checked for changes on the reload interval
+type Config struct {
+	CertFile       string
+	KeyFile        string
+	ClientCaFile   string
+	ClientAuth     string
+	ReloadInterval time.Duration
+}
+
+// tls configuration of the server, rotated certificates and ca bundles are loaded without restart
+type Server struct {
+	config  *Config
+	current atomic.Pointer[tls.Config]
+	modTime time.Time
+}
+
+// function to create the tls configuration of the server, the files are loaded once, so that invalid files fail startup
+// files are checked for changes until the context is done
+func NewServer(ctx context.Context, config *Config) (*Server, error) {
+	server := &Server{config: config}
+	if err := server.load(); err != nil {
+		return nil, err
+	}
+	if config.ReloadInterval > 0 {
+		go server.watch(ctx)
+	}
+	return server, nil
+}
+
+// function to get the tls configuration of the http server, http/2 is negotiated with clients which support it
+func (s *Server) TlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return s.current.Load(), nil
+		},
+	}
+}
+
+func (s *Server) watch(ctx context.Context) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	ticker := time.NewTicker(s.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			modTime, err := s.lastModified()
+			if err != nil || !modTime.After(s.modTime) {
+				continue
+			}
+			// certificate and key are not replaced at once, so a failed load is retried on the next interval
+			if lerror := s.load(); lerror != nil {
+				logger.Warnf("tls files are not reloaded, current certificate is kept, error: %v", lerror)
+				continue
+			}
+			leaf := s.current.Load().Certificates[0].Leaf
+			logger.Infof("tls certificate reloaded, subject: %s, expires: %s", leaf.Subject, leaf.NotAfter.Format(time.RFC3339))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// function to load certificate, key and client ca bundle
+func (s *Server) load() error {
+	modTime, err := s.lastModified()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(s.config.CertFile, s.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load tls certificate: %w", err)
+	}
+	if certificate.Leaf == nil {
+		if certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0]); err != nil {
+			return fmt.Errorf("cannot parse tls certificate: %w", err)
+		}
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{certificate},
+	}
+	switch strings.ToLower(s.config.ClientAuth) {
+	case "", ClientAuthNone:
+		config.ClientAuth = tls.NoClientCert
+	case ClientAuthRequest:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return fmt.Errorf("invalid client auth '%s', expected none, request or require", s.config.ClientAuth)
+	}
+	if config.ClientAuth != tls.NoClientCert {
+		bundle, rerror := os.ReadFile(s.config.ClientCaFile)
+		if rerror != nil {
+			return fmt.Errorf("cannot read client ca bundle: %w", rerror)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(bundle) {
+			return errors.New("client ca bundle does not contain pem certificates")
+		}
+	}
+	s.current.Store(config)
+	s.modTime = modTime
+	return nil
+}
+
+// function to get the latest modification of the files, secrets mounted by kubernetes are replaced by symlink, which is followed by stat
+func (s *Server) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{s.config.CertFile, s.config.KeyFile, s.config.ClientCaFile} {
+		if len(file) == 0 {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
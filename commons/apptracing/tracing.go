@@ -0,0 +1,148 @@
+package apptracing
+
+import (
+	"GolangCourse/commons/apploggers"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// spans are not exported, trace context is still propagated
+	ExporterNone = "none"
+	// spans are exported with otlp over http, e.g. to a collector or jaeger
+	ExporterOtlp = "otlp"
+	// spans are written as json to stdout or to a file, for local development
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+
+	tracerName = "GolangCourse"
+)
+
+// configuration of the tracing
+type Config struct {
+	Exporter    string
+	ServiceName string
+	// endpoint of the otlp receiver, e.g. "http://localhost:4318", OTEL_EXPORTER_OTLP_* variables are used when empty
+	OtlpEndpoint string
+	FilePath     string
+	// share of traces which are sampled, traces of sampled callers are always sampled
+	SampleRatio float64
+}
+
+var (
+	shutdownMux sync.Mutex
+	shutdown    func(ctx context.Context) error
+)
+
+func init() {
+	// trace context of callers is propagated even when spans are not exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// function to configure the tracer provider of all spans
+func Configure(ctx context.Context, config *Config) error {
+	exporter, closer, err := newExporter(ctx, config)
+	if err != nil {
+		return err
+	}
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.ServiceName))),
+	}
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+
+	shutdownMux.Lock()
+	previous := shutdown
+	shutdown = func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}
+	shutdownMux.Unlock()
+	if previous != nil {
+		return previous(ctx)
+	}
+	return nil
+}
+
+// function to flush pending spans and stop the exporter, e.g. before exit
+func Shutdown(ctx context.Context) error {
+	shutdownMux.Lock()
+	defer shutdownMux.Unlock()
+	if shutdown == nil {
+		return nil
+	}
+	err := shutdown(ctx)
+	shutdown = nil
+	return err
+}
+
+func newExporter(ctx context.Context, config *Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch strings.ToLower(strings.TrimSpace(config.Exporter)) {
+	case "", ExporterNone:
+		return nil, nil, nil
+	case ExporterOtlp:
+		var options []otlptracehttp.Option
+		if len(config.OtlpEndpoint) > 0 {
+			options = append(options, otlptracehttp.WithEndpointURL(config.OtlpEndpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		return exporter, nil, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nil, err
+	case ExporterFile:
+		if len(config.FilePath) == 0 {
+			return nil, nil, errors.New("trace file path is required for file exporter")
+		}
+		file, err := os.OpenFile(config.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	}
+	return nil, nil, fmt.Errorf("invalid trace exporter '%s', expected none, otlp, stdout or file", config.Exporter)
+}
+
+// function to start a span of the service, the span must be ended with End
+func Start(ctx context.Context, name string, kind trace.SpanKind, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes...))
+}
+
+// function to end the span, the error is recorded as status of the span
+// errors are redacted like logs, as spans are exported to other systems
+func End(span trace.Span, err error) {
+	if err != nil {
+		message := apploggers.Redact(err.Error())
+		span.AddEvent("exception", trace.WithAttributes(semconv.ExceptionMessage(message)))
+		span.SetStatus(codes.Error, message)
+	}
+	span.End()
+}
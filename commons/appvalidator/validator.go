@@ -0,0 +1,114 @@
+package appvalidator
+
+import (
+	"GolangCourse/commons/apperrors"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// name of embedded structs in namespaces, their fields are promoted like in json so the name is removed from json paths
+const embeddedName = "^"
+
+// validator for payloads, driven by `validate` struct tags
+// implements echo.Validator so that it can be used with echo context Validate
+type AppValidator struct {
+	validate *validator.Validate
+}
+
+var defaultValidator = NewValidator()
+
+func NewValidator() *AppValidator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	// report fields by json name, so that errors are keyed by json path
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if field.Anonymous && len(name) == 0 {
+			return embeddedName
+		}
+		if len(name) == 0 {
+			return field.Name
+		}
+		return name
+	})
+	validate.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return len(strings.TrimSpace(fl.Field().String())) > 0
+	})
+	return &AppValidator{validate: validate}
+}
+
+// function to validate the payload, all field errors are returned at once
+// error details are keyed by json path of the field e.g. "email"
+func (v *AppValidator) Validate(payload interface{}) error {
+	return toAppError(v.validate.Struct(payload))
+}
+
+// function to validate only the given fields of the payload, fields are the struct field names e.g. "Email"
+func (v *AppValidator) ValidatePartial(payload interface{}, fields ...string) error {
+	return toAppError(v.validate.StructPartial(payload, fields...))
+}
+
+// function to convert validation errors to invalid argument error with details keyed by json path
+func toAppError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return apperrors.NewInvalidArgument("invalid request payload")
+	}
+	details := make(map[string]interface{}, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		details[getJsonPath(fieldError)] = getMessage(fieldError)
+	}
+	return apperrors.NewInvalidArgument("invalid request payload").WithDetails(details)
+}
+
+// function to validate the payload with default validator
+func ValidateStruct(payload interface{}) error {
+	return defaultValidator.Validate(payload)
+}
+
+// function to validate the given fields of the payload with default validator
+func ValidateStructPartial(payload interface{}, fields ...string) error {
+	return defaultValidator.ValidatePartial(payload, fields...)
+}
+
+// function to get json path from the namespace of field, root struct name is removed
+func getJsonPath(fieldError validator.FieldError) string {
+	namespace := strings.ReplaceAll(fieldError.Namespace(), "."+embeddedName, "")
+	if index := strings.Index(namespace, "."); index >= 0 {
+		return namespace[index+1:]
+	}
+	return namespace
+}
+
+// function to get message for the failed validation tag
+func getMessage(fieldError validator.FieldError) string {
+	isString := fieldError.Kind() == reflect.String
+	switch fieldError.Tag() {
+	case "required", "notblank":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	case "max", "lte":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters", fieldError.Param())
+		}
+		return "must be at most " + fieldError.Param()
+	case "min", "gte":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters", fieldError.Param())
+		}
+		return "must be at least " + fieldError.Param()
+	}
+	return fmt.Sprintf("failed '%s' validation", fieldError.Tag())
+}
@@ -1,18 +1,28 @@
 package commons
 
 import (
-	"encoding/json"
+	"net/http"
 )
 
+const ProblemJsonContentType = "application/problem+json"
+
 type ApiErrorResponsePayload struct {
 	Status         string                 `json:"status"`
 	Message        string                 `json:"message"`
 	AdditionalInfo map[string]interface{} `json:"additional_info,omitempty"`
 }
 
-func PrintStruct(payload interface{}) string {
-	pbytes, _ := json.Marshal(payload)
-	return string(pbytes)
+// RFC 7807 problem details response, extends api error response with the problem fields
+// status of the api error response is replaced by the http status code
+type ProblemDetails struct {
+	Type          string `json:"type"`
+	Title         string `json:"title"`
+	Status        int    `json:"status"`
+	Detail        string `json:"detail,omitempty"`
+	Instance      string `json:"instance,omitempty"`
+	Code          string `json:"code,omitempty"`
+	CorrelationId string `json:"correlation_id,omitempty"`
+	*ApiErrorResponsePayload
 }
 
 func ApiErrorResponse(message string, additionalInfo map[string]interface{}) *ApiErrorResponsePayload {
@@ -25,3 +35,14 @@ func ApiErrorResponse(message string, additionalInfo map[string]interface{}) *Ap
 	}
 	return response
 }
+
+func ProblemResponse(status int, code string, message string, additionalInfo map[string]interface{}) *ProblemDetails {
+	return &ProblemDetails{
+		Type:                    "about:blank",
+		Title:                   http.StatusText(status),
+		Status:                  status,
+		Detail:                  message,
+		Code:                    code,
+		ApiErrorResponsePayload: ApiErrorResponse(message, additionalInfo),
+	}
+}
@@ -1,10 +0,0 @@
-package configs
-
-const (
-	HTTP_PORT = "HTTP_PORT"
-
-	MONGO_URI      = "MONGO_URI"
-	MONGO_USER     = "MONGO_USER"
-	MONGO_PASSWORD = "MONGO_PASSWORD"
-	MONGO_DATABASE = "MONGO_DATABASE"
-)
@@ -1,45 +1,413 @@
 package configs
 
 import (
+	"GolangCourse/commons/appauth"
 	"GolangCourse/commons/appdb"
+	"GolangCourse/commons/appfeatures"
 	"GolangCourse/commons/apploggers"
-	"context"
-	"os"
-
-	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"GolangCourse/commons/appratelimit"
+	"GolangCourse/commons/apptls"
+	"GolangCourse/commons/apptracing"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
 )
 
-var (
-	AppConfig *ApplicationConfig
+// profiles of the environments, profiles select the defaults and the profile config file
+const (
+	ProfileDev     = "dev"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
 )
 
-type ApplicationConfig struct {
-	HttpPort string
-	DbClient appdb.DatabaseClient
+// configuration of the application
+// values are loaded from defaults, the config file, .env, environment variables and flags, later sources take precedence
+// yaml tags are the keys of the config file and the names of the flags, e.g. "--log.level", env tags the environment variables
+// secret values are redacted by config print, of urls only the password is redacted
+type Config struct {
+	Profile   string          `yaml:"profile" env:"APP_PROFILE" usage:"dev, staging or prod"`
+	Http      HttpConfig      `yaml:"http"`
+	Tls       TlsConfig       `yaml:"tls"`
+	Mongo     MongoConfig     `yaml:"mongo"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Password  PasswordConfig  `yaml:"password"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	AccessLog AccessLogConfig `yaml:"access_log"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Cors      CorsConfig      `yaml:"cors"`
+	Features  map[string]bool `yaml:"features" env:"FEATURE_FLAGS" usage:"flags of features, e.g. \"user-export=false\""`
+
+	// sources of the values by path, e.g. "log.level" -> "env LOG_LEVEL"
+	sources map[string]string
+	// files the configuration is loaded from, watched for reload
+	files []string
+	// rate limits are parsed once, as buckets of the limiter are reset when rules change
+	rateLimits *appratelimit.Config
+}
+
+type HttpConfig struct {
+	Port              int           `yaml:"port" env:"HTTP_PORT" usage:"port of the http server"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"time keep-alive connections are kept open without requests"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES"`
+	MaxBodySize       string        `yaml:"max_body_size" env:"HTTP_MAX_BODY_SIZE" usage:"max size of request bodies, e.g. 1M"`
+	RouteBodySizes    string        `yaml:"route_body_sizes" env:"HTTP_ROUTE_BODY_SIZES" usage:"max body sizes of routes, e.g. \"POST /users/import=64M\""`
+	RequestTimeout    time.Duration `yaml:"request_timeout" env:"HTTP_REQUEST_TIMEOUT" usage:"deadline of requests, propagated to services and db operations"`
+	RouteTimeouts     string        `yaml:"route_timeouts" env:"HTTP_ROUTE_TIMEOUTS" usage:"deadlines of routes, e.g. \"GET /users/export=10m\""`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" usage:"time in-flight requests are completed on SIGINT and SIGTERM"`
+}
+
+type TlsConfig struct {
+	CertFile         string        `yaml:"cert_file" env:"TLS_CERT_FILE" usage:"certificate of the server, https is served when set"`
+	KeyFile          string        `yaml:"key_file" env:"TLS_KEY_FILE"`
+	ClientCaFile     string        `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE" usage:"ca bundle of client certificates"`
+	ClientAuth       string        `yaml:"client_auth" env:"TLS_CLIENT_AUTH" usage:"none, request or require"`
+	ClientPrincipals string        `yaml:"client_principals" env:"TLS_CLIENT_PRINCIPALS" usage:"scopes of client certificates by common name, e.g. \"billing=users:read\""`
+	MtlsNetworks     []string      `yaml:"mtls_networks" env:"TLS_MTLS_NETWORKS" usage:"networks of callers which must authenticate with client certificates"`
+	ReloadInterval   time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL" usage:"interval of the checks for rotated certificates"`
+}
+
+type MongoConfig struct {
+	Uri            string        `yaml:"uri" env:"MONGO_URI" secret:"url" usage:"connection string of the mongo server"`
+	User           string        `yaml:"user" env:"MONGO_USER"`
+	Password       string        `yaml:"password" env:"MONGO_PASSWORD" secret:"true"`
+	Database       string        `yaml:"database" env:"MONGO_DATABASE"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"MONGO_CONNECT_TIMEOUT" usage:"timeout of the connection check at startup"`
+}
+
+type AuthConfig struct {
+	Disabled         bool          `yaml:"disabled" env:"AUTH_DISABLED" usage:"disables authentication, only allowed in dev profile"`
+	Jwt              JwtConfig     `yaml:"jwt"`
+	AccessTokenTtl   time.Duration `yaml:"access_token_ttl" env:"AUTH_ACCESS_TOKEN_TTL"`
+	RefreshTokenTtl  time.Duration `yaml:"refresh_token_ttl" env:"AUTH_REFRESH_TOKEN_TTL"`
+	LockoutThreshold int           `yaml:"lockout_threshold" env:"AUTH_LOCKOUT_THRESHOLD" usage:"failed logins after which the account is locked"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration" env:"AUTH_LOCKOUT_DURATION"`
+	TotpIssuer       string        `yaml:"totp_issuer" env:"AUTH_TOTP_ISSUER"`
+	TotpKeyFile      string        `yaml:"totp_key_file" env:"AUTH_TOTP_KEY_FILE" usage:"file with the key of totp secrets at rest, at least 32 bytes, required by login"`
+}
+
+type JwtConfig struct {
+	Issuer         string        `yaml:"issuer" env:"AUTH_JWT_ISSUER"`
+	Audience       string        `yaml:"audience" env:"AUTH_JWT_AUDIENCE"`
+	HmacSecretFile string        `yaml:"hmac_secret_file" env:"AUTH_JWT_HMAC_SECRET_FILE"`
+	PublicKeyFile  string        `yaml:"public_key_file" env:"AUTH_JWT_PUBLIC_KEY_FILE"`
+	JwksFile       string        `yaml:"jwks_file" env:"AUTH_JWT_JWKS_FILE"`
+	Leeway         time.Duration `yaml:"leeway" env:"AUTH_JWT_LEEWAY"`
+	SigningKeyFile string        `yaml:"signing_key_file" env:"AUTH_JWT_SIGNING_KEY_FILE"`
+	SigningKeyId   string        `yaml:"signing_key_id" env:"AUTH_JWT_SIGNING_KEY_ID"`
+}
+
+type PasswordConfig struct {
+	MinLength  int `yaml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	MinClasses int `yaml:"min_classes" env:"PASSWORD_MIN_CLASSES" usage:"min number of character classes, 1 to 4"`
+}
+
+type RateLimitConfig struct {
+	Default  string `yaml:"default" env:"RATE_LIMIT_DEFAULT" usage:"limit of routes without own limit, e.g. 300/m"`
+	Routes   string `yaml:"routes" env:"RATE_LIMIT_ROUTES" usage:"limits of routes, e.g. \"GET /users=30/m\""`
+	ClientIp string `yaml:"client_ip" env:"RATE_LIMIT_CLIENT_IP" usage:"limit of all requests of a client ip before authentication, e.g. 600/m"`
+}
+
+type AccessLogConfig struct {
+	Exclude    []string `yaml:"exclude" env:"ACCESS_LOG_EXCLUDE" usage:"path prefixes which are not logged"`
+	SampleRate float64  `yaml:"sample_rate" env:"ACCESS_LOG_SAMPLE_RATE" usage:"share of successful requests which are logged"`
+}
+
+type MetricsConfig struct {
+	Token string `yaml:"token" env:"METRICS_TOKEN" secret:"true" usage:"bearer token of scrapers"`
+}
+
+type LogConfig struct {
+	Level         string             `yaml:"level" env:"LOG_LEVEL"`
+	Format        string             `yaml:"format" env:"LOG_FORMAT" usage:"console or json"`
+	Outputs       []string           `yaml:"outputs" env:"LOG_OUTPUTS" usage:"stdout, stderr, file and syslog"`
+	PackageLevels string             `yaml:"package_levels" env:"LOG_PACKAGE_LEVELS"`
+	RecentSize    int                `yaml:"recent_size" env:"LOG_RECENT_SIZE"`
+	File          LogFileConfig      `yaml:"file"`
+	Syslog        LogSyslogConfig    `yaml:"syslog"`
+	Redaction     LogRedactionConfig `yaml:"redaction"`
+}
+
+type LogFileConfig struct {
+	Path           string        `yaml:"path" env:"LOG_FILE_PATH"`
+	MaxSizeMb      int           `yaml:"max_size_mb" env:"LOG_FILE_MAX_SIZE_MB"`
+	MaxAgeDays     int           `yaml:"max_age_days" env:"LOG_FILE_MAX_AGE_DAYS"`
+	MaxBackups     int           `yaml:"max_backups" env:"LOG_FILE_MAX_BACKUPS"`
+	RotateInterval time.Duration `yaml:"rotate_interval" env:"LOG_FILE_ROTATE_INTERVAL"`
+	Compress       bool          `yaml:"compress" env:"LOG_FILE_COMPRESS"`
+}
+
+type LogSyslogConfig struct {
+	Network string `yaml:"network" env:"LOG_SYSLOG_NETWORK"`
+	Address string `yaml:"address" env:"LOG_SYSLOG_ADDRESS"`
+	Tag     string `yaml:"tag" env:"LOG_SYSLOG_TAG"`
+}
+
+type LogRedactionConfig struct {
+	Mode    string   `yaml:"mode" env:"LOG_REDACTION" usage:"mask, hash or off"`
+	Fields  []string `yaml:"fields" env:"LOG_REDACTION_FIELDS"`
+	HashKey string   `yaml:"hash_key" env:"LOG_REDACTION_HASH_KEY" secret:"true"`
+}
+
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER" usage:"none, otlp, stdout or file"`
+	ServiceName  string  `yaml:"service_name" env:"TRACING_SERVICE_NAME"`
+	OtlpEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	FilePath     string  `yaml:"file_path" env:"TRACING_FILE_PATH"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type CorsConfig struct {
+	AllowOrigins []string `yaml:"allow_origins" env:"CORS_ALLOW_ORIGINS" usage:"origins of browser clients, * allows all origins"`
+}
+
+// function to get the defaults of the profile
+func defaultConfig(profile string) *Config {
+	config := &Config{
+		Profile: profile,
+		// export and import stream the whole collection, so their limits are higher
+		Http: HttpConfig{
+			Port:              3000,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       time.Minute,
+			WriteTimeout:      time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    64 << 10,
+			MaxBodySize:       "1M",
+			RouteBodySizes:    "POST /users/import=64M",
+			RequestTimeout:    30 * time.Second,
+			RouteTimeouts:     "GET /users/export=10m,POST /users/import=10m",
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Tls: TlsConfig{ClientAuth: apptls.ClientAuthNone, ReloadInterval: time.Minute},
+		Mongo: MongoConfig{
+			Uri:            "mongodb://localhost:27017",
+			Database:       "user-management",
+			ConnectTimeout: 10 * time.Second,
+		},
+		Auth: AuthConfig{
+			Jwt:              JwtConfig{Leeway: 30 * time.Second},
+			AccessTokenTtl:   15 * time.Minute,
+			RefreshTokenTtl:  720 * time.Hour,
+			LockoutThreshold: 5,
+			LockoutDuration:  15 * time.Minute,
+			TotpIssuer:       "User Management",
+		},
+		Password: PasswordConfig{MinLength: 12, MinClasses: 3},
+		RateLimit: RateLimitConfig{
+			Default: "300/m",
+			// the whole collection is loaded by list, export and import, so their limits are lower
+			Routes:   "GET /users=30/m,GET /users/export=5/m,POST /users/import=5/m,POST /auth/login=10/m,POST /auth/refresh=30/m",
+			ClientIp: "600/m",
+		},
+		AccessLog: AccessLogConfig{Exclude: []string{"/swagger", "/health", "/metrics"}, SampleRate: 1},
+		Log: LogConfig{
+			Level:      "info",
+			Format:     "console",
+			Outputs:    []string{"stdout"},
+			RecentSize: 5000,
+			File: LogFileConfig{
+				Path:           "logs/user-management.log",
+				MaxSizeMb:      100,
+				MaxAgeDays:     14,
+				MaxBackups:     10,
+				RotateInterval: 24 * time.Hour,
+				Compress:       true,
+			},
+			Syslog:    LogSyslogConfig{Tag: "user-management"},
+			Redaction: LogRedactionConfig{Mode: apploggers.RedactionMask},
+		},
+		Tracing: TracingConfig{
+			Exporter:    apptracing.ExporterNone,
+			ServiceName: "user-management",
+			FilePath:    "logs/traces.json",
+			SampleRatio: 1,
+		},
+		Features: appfeatures.Defaults(),
+	}
+	switch profile {
+	case ProfileDev:
+		config.Log.Level = "debug"
+	case ProfileStaging, ProfileProd:
+		// logs are collected by the platform, json is parsed without patterns
+		config.Log.Format = "json"
+	}
+	if profile == ProfileProd {
+		config.Tracing.SampleRatio = 0.1
+	}
+	return config
+}
+
+// function to get the configuration of the loggers
+func (c *Config) LoggerConfig() *apploggers.Config {
+	return &apploggers.Config{
+		Level:          c.Log.Level,
+		Format:         c.Log.Format,
+		Outputs:        c.Log.Outputs,
+		PackageLevels:  c.Log.PackageLevels,
+		RecentLogsSize: c.Log.RecentSize,
+		File: apploggers.FileConfig{
+			Path:           c.Log.File.Path,
+			MaxSizeMb:      c.Log.File.MaxSizeMb,
+			MaxAgeDays:     c.Log.File.MaxAgeDays,
+			MaxBackups:     c.Log.File.MaxBackups,
+			RotateInterval: c.Log.File.RotateInterval,
+			Compress:       c.Log.File.Compress,
+		},
+		Syslog: apploggers.SyslogConfig{
+			Network: c.Log.Syslog.Network,
+			Address: c.Log.Syslog.Address,
+			Tag:     c.Log.Syslog.Tag,
+		},
+		Redaction: apploggers.RedactionConfig{
+			Mode:    c.Log.Redaction.Mode,
+			Fields:  c.Log.Redaction.Fields,
+			HashKey: c.Log.Redaction.HashKey,
+		},
+	}
+}
+
+// function to get the configuration of the tracing
+func (c *Config) TracerConfig() *apptracing.Config {
+	return &apptracing.Config{
+		Exporter:     c.Tracing.Exporter,
+		ServiceName:  c.Tracing.ServiceName,
+		OtlpEndpoint: c.Tracing.OtlpEndpoint,
+		FilePath:     c.Tracing.FilePath,
+		SampleRatio:  c.Tracing.SampleRatio,
+	}
+}
+
+// function to get the http server with the timeouts of the configuration, read and write deadlines of routes with longer timeouts are moved by the deadline middleware
+func (c *Config) HttpServer() *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", c.Http.Port),
+		ReadHeaderTimeout: c.Http.ReadHeaderTimeout,
+		ReadTimeout:       c.Http.ReadTimeout,
+		WriteTimeout:      c.Http.WriteTimeout,
+		IdleTimeout:       c.Http.IdleTimeout,
+		MaxHeaderBytes:    c.Http.MaxHeaderBytes,
+	}
+}
+
+// function to get the max body size and the sizes of routes in bytes, sizes are validated on load
+func (c *Config) BodyLimits() (int64, map[string]int64) {
+	limit, _ := parseSize(c.Http.MaxBodySize)
+	routes, _ := parseRouteValues(c.Http.RouteBodySizes, parseSize)
+	return limit, routes
+}
+
+// function to get the request timeout and the timeouts of routes, timeouts are validated on load
+func (c *Config) RequestTimeouts() (time.Duration, map[string]time.Duration) {
+	routes, _ := parseRouteValues(c.Http.RouteTimeouts, time.ParseDuration)
+	return c.Http.RequestTimeout, routes
+}
+
+// function to get the configuration of the https server, nil when https is not configured
+func (c *Config) ServerTlsConfig() *apptls.Config {
+	if len(c.Tls.CertFile) == 0 {
+		return nil
+	}
+	return &apptls.Config{
+		CertFile:       c.Tls.CertFile,
+		KeyFile:        c.Tls.KeyFile,
+		ClientCaFile:   c.Tls.ClientCaFile,
+		ClientAuth:     c.Tls.ClientAuth,
+		ReloadInterval: c.Tls.ReloadInterval,
+	}
 }
 
-func NewApplicationConfig(context context.Context) error {
-	logger := apploggers.GetLoggerWithCorrelationid(context)
-	if err := godotenv.Load(".env"); err != nil {
-		return err
+// function to get the networks of callers which must authenticate with client certificates, networks are validated on load
+func (c *Config) MtlsNetworks() []netip.Prefix {
+	var networks []netip.Prefix
+	for _, network := range c.Tls.MtlsNetworks {
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			networks = append(networks, prefix.Masked())
+		}
 	}
+	return networks
+}
 
-	// Create new mongo client and connect to the server
-	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	opts := options.Client().ApplyURI(os.Getenv(MONGO_URI)).SetServerAPIOptions(serverAPI)
-	client, cerror := mongo.Connect(context, opts)
-	if cerror != nil {
-		logger.Errorf("Error while connecting db, error: ", cerror)
-		panic(cerror)
+// function to get the configuration of the mongo connection
+func (c *Config) DbConfig() *appdb.Config {
+	return &appdb.Config{
+		Uri:            c.Mongo.Uri,
+		User:           c.Mongo.User,
+		Password:       c.Mongo.Password,
+		Database:       c.Mongo.Database,
+		ConnectTimeout: c.Mongo.ConnectTimeout,
 	}
+}
 
-	logger.Info("You successfully connected to MongoDB!")
-	dbClient := appdb.NewDatabaseClient(os.Getenv(MONGO_DATABASE), client)
-	AppConfig = &ApplicationConfig{
-		HttpPort: os.Getenv(HTTP_PORT),
-		DbClient: dbClient,
+// function to get the configuration of the jwt verifier and signer
+func (c *Config) AuthJwtConfig() *appauth.JwtConfig {
+	return &appauth.JwtConfig{
+		Issuer:         c.Auth.Jwt.Issuer,
+		Audience:       c.Auth.Jwt.Audience,
+		HmacSecretFile: c.Auth.Jwt.HmacSecretFile,
+		PublicKeyFile:  c.Auth.Jwt.PublicKeyFile,
+		JwksFile:       c.Auth.Jwt.JwksFile,
+		Leeway:         c.Auth.Jwt.Leeway,
+		SigningKeyFile: c.Auth.Jwt.SigningKeyFile,
+		SigningKeyId:   c.Auth.Jwt.SigningKeyId,
+	}
+}
+
+// function to get the password policy of the auth service
+func (c *Config) PasswordPolicy() *appauth.PasswordPolicy {
+	return &appauth.PasswordPolicy{
+		MinLength:  c.Password.MinLength,
+		MinClasses: c.Password.MinClasses,
+	}
+}
+
+// function to get the rate limits, limits are validated on load
+func (c *Config) RateLimits() *appratelimit.Config {
+	return c.rateLimits
+}
+
+// function to parse comma separated "<method> <path>=<value>", e.g. "GET /users/export=10m"
+// routes are keyed by method and route template like the rate limits
+func parseRouteValues[T any](text string, parse func(string) (T, error)) (map[string]T, error) {
+	values := map[string]T{}
+	for _, entry := range strings.Split(text, ",") {
+		if len(strings.TrimSpace(entry)) == 0 {
+			continue
+		}
+		route, value, found := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !found || !hasPath {
+			return nil, fmt.Errorf("invalid route value '%s', expected <method> <path>=<value>", entry)
+		}
+		parsed, err := parse(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of route %s: %w", route, err)
+		}
+		values[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = parsed
+	}
+	return values, nil
+}
+
+// function to parse sizes in bytes with optional K, M or G suffix, e.g. "512K" or "1M"
+func parseSize(text string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(text))
+	multiplier := int64(1)
+	for suffix, value := range map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30} {
+		if strings.HasSuffix(number, suffix) {
+			number, multiplier = strings.TrimSuffix(number, suffix), value
+			break
+		}
+	}
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("'%s' is not a size, expected e.g. 1M", text)
 	}
-	return nil
+	return size * multiplier, nil
 }
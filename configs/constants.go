@@ -1,12 +1,8 @@
 package configs
 
 const (
-	HTTP_PORT = "HTTP_PORT"
-
-	MONGO_URI      = "MONGO_URI"
-	MONGO_USER     = "MONGO_USER"
-	MONGO_PASSWORD = "MONGO_PASSWORD"
-	MONGO_DATABASE = "MONGO_DATABASE"
-
-	MONGO_USERS_COLLECTION = "users"
+	MONGO_USERS_COLLECTION          = "users"
+	MONGO_API_KEYS_COLLECTION       = "api_keys"
+	MONGO_CREDENTIALS_COLLECTION    = "credentials"
+	MONGO_REFRESH_TOKENS_COLLECTION = "refresh_tokens"
 )
@@ -0,0 +1,315 @@
+package configs
+
+import (
+	"GolangCourse/commons/appratelimit"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	APP_CONFIG_FILE = "APP_CONFIG_FILE"
+
+	defaultConfigFile = "config.yaml"
+	dotenvFile        = ".env"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// function to load the configuration, sources are applied in order of precedence:
+// defaults of the profile, config file, profile config file, .env, environment variables and flags
+// args are the command line arguments without program name, arguments after the flags are returned, e.g. the command
+func Load(args []string) (*Config, []string, error) {
+	flags, flagValues, configFile := newFlagSet()
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	// .env does not override environment variables, so that it is only a fallback of the environment
+	// it is read on every load instead of being added to the environment, so that changes are reloaded
+	dotenv, err := godotenv.Read(dotenvFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%s: %w", dotenvFile, err)
+	}
+	// profile selects the defaults, so it is resolved before the other values
+	// prod is the default, so that deployments without profile do not run with the settings of developer machines
+	profile := ProfileProd
+	lookupEnv := func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, ok
+		}
+		// blank keys of .env are placeholders of developer machines, they do not reset values of the config files
+		value, ok := dotenv[key]
+		if profile == ProfileDev {
+			return value, len(value) > 0
+		}
+		return value, ok
+	}
+
+	path, required := *configFile, true
+	if len(path) == 0 {
+		var ok bool
+		if path, ok = lookupEnv(APP_CONFIG_FILE); !ok {
+			path, required = defaultConfigFile, false
+		}
+	}
+	content, err := readConfigFile(path, required)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(content) > 0 {
+		var header struct {
+			Profile string `yaml:"profile"`
+		}
+		if err := yaml.Unmarshal(content, &header); err != nil {
+			return nil, nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		profile = getOrDefault(header.Profile, profile)
+	}
+	if value, ok := lookupEnv("APP_PROFILE"); ok && len(strings.TrimSpace(value)) > 0 {
+		profile = value
+	}
+	if value, ok := flagValues["profile"]; ok {
+		profile = value
+	}
+	profile = strings.ToLower(strings.TrimSpace(profile))
+
+	config := defaultConfig(profile)
+	config.sources = map[string]string{}
+	config.files = []string{path, dotenvFile}
+	if err := config.applyFile(path, content); err != nil {
+		return nil, nil, err
+	}
+	profilePath := filepath.Join(filepath.Dir(path), "config."+profile+".yaml")
+	profileContent, err := readConfigFile(profilePath, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := config.applyFile(profilePath, profileContent); err != nil {
+		return nil, nil, err
+	}
+	config.files = append(config.files, profilePath)
+	if err := config.applyEnv(lookupEnv); err != nil {
+		return nil, nil, err
+	}
+	if err := config.applyFlags(flagValues); err != nil {
+		return nil, nil, err
+	}
+	// the profile of the files and environment variables must not differ from the resolved profile
+	config.Profile = profile
+
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	config.rateLimits, _ = appratelimit.ParseConfig(config.RateLimit.Default, config.RateLimit.Routes, config.RateLimit.ClientIp)
+	return config, flags.Args(), nil
+}
+
+// function to get the source of the value, e.g. "env LOG_LEVEL", default values have no recorded source
+func (c *Config) Source(path string) string {
+	if source, ok := c.sources[path]; ok {
+		return source
+	}
+	return "default"
+}
+
+// function to create the flags of all values, e.g. "--log.level debug" and "--auth.disabled"
+func newFlagSet() (*flag.FlagSet, map[string]string, *string) {
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	values := map[string]string{}
+	configFile := flags.String("config", "", "path of the yaml config file, defaults to "+APP_CONFIG_FILE+" or "+defaultConfigFile)
+	walkFields(reflect.ValueOf(defaultConfig(ProfileDev)).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		usage := field.Tag.Get("usage")
+		if env := field.Tag.Get("env"); len(env) > 0 {
+			usage = strings.TrimSpace(usage + " (env " + env + ")")
+		}
+		set := func(text string) error {
+			values[path] = text
+			return nil
+		}
+		if value.Kind() == reflect.Bool {
+			flags.BoolFunc(path, usage, set)
+		} else {
+			flags.Func(path, usage, set)
+		}
+	})
+	return flags, values, configFile
+}
+
+// function to read the config file, a missing file is an error only when the file is required
+func readConfigFile(path string, required bool) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	return content, nil
+}
+
+// function to apply the values of the yaml file, unknown keys are rejected, so that typos are not ignored
+func (c *Config) applyFile(path string, content []byte) error {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	if len(document.Content) > 0 {
+		c.recordFileSources(document.Content[0], "", "file "+path)
+	}
+	return nil
+}
+
+// function to record the source of all values of the yaml mapping
+func (c *Config) recordFileSources(node *yaml.Node, prefix string, source string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		path := prefix + node.Content[i].Value
+		c.sources[path] = source
+		if value := node.Content[i+1]; value.Kind == yaml.MappingNode {
+			c.recordFileSources(value, path+".", source)
+		}
+	}
+}
+
+// function to apply the environment variables, including the variables of .env
+func (c *Config) applyEnv(lookupEnv func(key string) (string, bool)) error {
+	var errs []error
+	walkFields(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		env := field.Tag.Get("env")
+		text, ok := lookupEnv(env)
+		if len(env) == 0 || !ok {
+			return
+		}
+		if err := setValue(value, text); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", env, err))
+			return
+		}
+		c.sources[path] = "env " + env
+	})
+	return errors.Join(errs...)
+}
+
+// function to apply the flags, flags take precedence over all other sources
+func (c *Config) applyFlags(values map[string]string) error {
+	var errs []error
+	walkFields(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		text, ok := values[path]
+		if !ok {
+			return
+		}
+		if err := setValue(value, text); err != nil {
+			errs = append(errs, fmt.Errorf("invalid --%s: %w", path, err))
+			return
+		}
+		c.sources[path] = "flag --" + path
+	})
+	return errors.Join(errs...)
+}
+
+// function to visit the values of the struct, path is the yaml path of the value, e.g. "log.file.path"
+func walkFields(value reflect.Value, prefix string, visit func(path string, field reflect.StructField, value reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if !field.IsExported() || len(name) == 0 {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			walkFields(value.Field(i), prefix+name+".", visit)
+			continue
+		}
+		visit(prefix+name, field, value.Field(i))
+	}
+}
+
+// function to parse the text into the value, lists are comma separated
+// maps are comma separated "<key>=<value>" and are merged into the current map, e.g. "user-export=false"
+func setValue(value reflect.Value, text string) error {
+	text = strings.TrimSpace(text)
+	if value.Type() == durationType {
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a boolean", text)
+		}
+		value.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("'%s' is not an integer", text)
+		}
+		value.SetInt(int64(parsed))
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a number", text)
+		}
+		value.SetFloat(parsed)
+	case reflect.Slice:
+		var values []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				values = append(values, item)
+			}
+		}
+		value.Set(reflect.ValueOf(values))
+	case reflect.Map:
+		values := map[string]bool{}
+		for _, key := range value.MapKeys() {
+			values[key.String()] = value.MapIndex(key).Bool()
+		}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); len(item) == 0 {
+				continue
+			}
+			key, enabled, found := strings.Cut(item, "=")
+			parsed, err := strconv.ParseBool(strings.TrimSpace(enabled))
+			if !found || err != nil {
+				return fmt.Errorf("'%s' is not <name>=<true|false>", item)
+			}
+			values[strings.TrimSpace(key)] = parsed
+		}
+		value.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+func getOrDefault(value string, defaultValue string) string {
+	if len(value) > 0 {
+		return value
+	}
+	return defaultValue
+}
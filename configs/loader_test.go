@@ -0,0 +1,181 @@
+package configs
+
+import (
+	"os"
+	"testing"
+)
+
+// function to run the test in an empty directory without the environment variables of the test, so that no .env or config file is found
+func isolateConfig(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	for _, key := range []string{APP_CONFIG_FILE, "APP_PROFILE", "HTTP_PORT", "LOG_LEVEL"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func writeConfigFile(t *testing.T, name string, content string) {
+	t.Helper()
+	if len(content) == 0 {
+		return
+	}
+	if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		profileFile string
+		dotenv      string
+		env         string
+		flag        string
+		port        int
+		source      string
+	}{
+		{"default", "", "", "", "", "", 3000, "default"},
+		{"config file", "8001", "", "", "", "", 8001, "file config.yaml"},
+		{"profile config file", "8001", "8002", "", "", "", 8002, "file config.prod.yaml"},
+		{".env", "8001", "8002", "8003", "", "", 8003, "env HTTP_PORT"},
+		{"environment variable", "8001", "8002", "8003", "8004", "", 8004, "env HTTP_PORT"},
+		{"flag", "8001", "8002", "8003", "8004", "8005", 8005, "flag --http.port"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isolateConfig(t)
+			if len(test.file) > 0 {
+				writeConfigFile(t, "config.yaml", "http:\n  port: "+test.file+"\n")
+			}
+			if len(test.profileFile) > 0 {
+				writeConfigFile(t, "config.prod.yaml", "http:\n  port: "+test.profileFile+"\n")
+			}
+			if len(test.dotenv) > 0 {
+				writeConfigFile(t, dotenvFile, "HTTP_PORT="+test.dotenv+"\n")
+			}
+			if len(test.env) > 0 {
+				t.Setenv("HTTP_PORT", test.env)
+			}
+			var args []string
+			if len(test.flag) > 0 {
+				args = []string{"--http.port", test.flag}
+			}
+			config, rest, err := Load(append(args, "serve"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Http.Port != test.port || config.Source("http.port") != test.source {
+				t.Fatalf("expected port %d of %s, got %d of %s", test.port, test.source, config.Http.Port, config.Source("http.port"))
+			}
+			if len(rest) != 1 || rest[0] != "serve" {
+				t.Fatalf("arguments after the flags must be returned, got %v", rest)
+			}
+		})
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     string
+		args    []string
+		profile string
+		level   string
+	}{
+		{"prod by default", "", "", nil, ProfileProd, "info"},
+		{"config file", "profile: dev\n", "", nil, ProfileDev, "debug"},
+		{"environment variable", "profile: dev\n", "Staging", nil, ProfileStaging, "info"},
+		{"blank environment variable", "profile: dev\n", " ", nil, ProfileDev, "debug"},
+		{"flag", "profile: staging\n", "prod", []string{"--profile", "dev"}, ProfileDev, "debug"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isolateConfig(t)
+			writeConfigFile(t, "config.yaml", test.file)
+			if len(test.env) > 0 {
+				t.Setenv("APP_PROFILE", test.env)
+			}
+			config, _, err := Load(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Profile != test.profile || config.Log.Level != test.level {
+				t.Fatalf("expected profile %s with level %s, got %s with %s", test.profile, test.level, config.Profile, config.Log.Level)
+			}
+		})
+	}
+}
+
+func TestLoadBlankDotenvValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		level   string
+		source  string
+	}{
+		{"are placeholders in dev", ProfileDev, "warn", "file config.yaml"},
+		{"reset values in prod", ProfileProd, "", "env LOG_LEVEL"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isolateConfig(t)
+			writeConfigFile(t, "config.yaml", "profile: "+test.profile+"\nlog:\n  level: warn\n")
+			writeConfigFile(t, dotenvFile, "LOG_LEVEL=\n")
+			config, _, err := Load(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Log.Level != test.level || config.Source("log.level") != test.source {
+				t.Fatalf("expected level %q of %s, got %q of %s", test.level, test.source, config.Log.Level, config.Source("log.level"))
+			}
+		})
+	}
+	// blank environment variables are values in all profiles, only .env has placeholders
+	isolateConfig(t)
+	writeConfigFile(t, "config.yaml", "profile: dev\nlog:\n  level: warn\n")
+	t.Setenv("LOG_LEVEL", "")
+	config, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Log.Level != "" {
+		t.Fatalf("blank environment variables must reset values, got %q", config.Log.Level)
+	}
+}
+
+func TestLoadRejectsInvalidSources(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  string
+		args []string
+	}{
+		{"unknown config key", "http:\n  prot: 8080\n", "", nil},
+		{"invalid environment variable", "", "eighty", nil},
+		{"invalid flag", "", "", []string{"--http.port", "eighty"}},
+		{"missing config file", "", "", []string{"--config", "missing.yaml"}},
+		{"invalid value", "http:\n  port: 70000\n", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isolateConfig(t)
+			writeConfigFile(t, "config.yaml", test.file)
+			if len(test.env) > 0 {
+				t.Setenv("HTTP_PORT", test.env)
+			}
+			if _, _, err := Load(test.args); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
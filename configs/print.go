@@ -0,0 +1,103 @@
+package configs
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "***"
+
+// function to print the configuration as yaml, secrets are redacted and the source of each value is added as comment
+func (c *Config) Print(w io.Writer) error {
+	document := &yaml.Node{Kind: yaml.MappingNode}
+	walkFields(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		node := mappingNode(document, strings.Split(path, ".")[:strings.Count(path, ".")])
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: field.Tag.Get("yaml")}
+		leaf := valueNode(value, field.Tag.Get("secret"))
+		leaf.LineComment = c.Source(path)
+		node.Content = append(node.Content, key, leaf)
+	})
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// function to get the mapping of the path, missing mappings are created
+func mappingNode(node *yaml.Node, path []string) *yaml.Node {
+	for _, name := range path {
+		var child *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				child = node.Content[i+1]
+			}
+		}
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+		}
+		node = child
+	}
+	return node
+}
+
+// function to get the node of the value, durations are printed as in the config file, e.g. "15m0s"
+func valueNode(value reflect.Value, secret string) *yaml.Node {
+	scalar := func(text string, tag string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: text, Tag: tag}
+	}
+	if value.Type() == durationType {
+		return scalar(time.Duration(value.Int()).String(), "!!str")
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return scalar(strconv.FormatBool(value.Bool()), "")
+	case reflect.Int:
+		return scalar(strconv.FormatInt(value.Int(), 10), "")
+	case reflect.Float64:
+		return scalar(strconv.FormatFloat(value.Float(), 'f', -1, 64), "")
+	case reflect.Slice:
+		sequence := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for i := 0; i < value.Len(); i++ {
+			sequence.Content = append(sequence.Content, scalar(value.Index(i).String(), "!!str"))
+		}
+		return sequence
+	case reflect.Map:
+		mapping := &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+		keys := value.MapKeys()
+		slices.SortFunc(keys, func(a reflect.Value, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		for _, key := range keys {
+			mapping.Content = append(mapping.Content, scalar(key.String(), "!!str"), valueNode(value.MapIndex(key), ""))
+		}
+		return mapping
+	}
+	return scalar(redact(value.String(), secret), "!!str")
+}
+
+// function to redact the secret, of urls only the password is redacted, so that host and user can be checked
+func redact(value string, secret string) string {
+	if len(value) == 0 || len(secret) == 0 {
+		return value
+	}
+	if secret == "url" {
+		parsed, err := url.Parse(value)
+		if err != nil {
+			return redactedValue
+		}
+		if _, ok := parsed.User.Password(); ok {
+			return strings.Replace(parsed.Redacted(), ":xxxxx@", ":"+redactedValue+"@", 1)
+		}
+		return value
+	}
+	return redactedValue
+}
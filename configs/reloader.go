@@ -0,0 +1,199 @@
+package configs
+
+import (
+	"GolangCourse/commons/apploggers"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// editors and config maps replace files in several steps, so changes are applied once the files are quiet
+const reloadDelay = 500 * time.Millisecond
+
+// reloader of the configuration, the current configuration is swapped atomically on reload
+type Reloader struct {
+	args     []string
+	current  atomic.Pointer[Config]
+	mutex    sync.Mutex
+	handlers []func(previous *Config, next *Config) (*ReloadChange, error)
+}
+
+// change prepared by a reload handler, it must not fail when it is applied
+// changes are applied once all handlers have prepared their changes, otherwise they are discarded
+type ReloadChange struct {
+	Apply   func()
+	Discard func()
+}
+
+// function to create the reloader, args are the command line arguments the configuration was loaded with
+func NewReloader(config *Config, args []string) *Reloader {
+	reloader := &Reloader{args: args}
+	reloader.current.Store(config)
+	return reloader
+}
+
+// function to get the current configuration, the configuration must not be changed
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// function to add a handler which prepares the change of the reloaded configuration, e.g. opens the log outputs
+// handlers are called in order before the swap, an error of a handler cancels the reload and nothing is changed
+// a nil change is returned when the handler has nothing to apply
+func (r *Reloader) OnReload(handler func(previous *Config, next *Config) (*ReloadChange, error)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.handlers = append(r.handlers, handler)
+}
+
+// function to reload the configuration, invalid configurations are rejected and the current configuration is kept
+// log, rate limits, cors and features are applied, changes of other values are applied on restart
+func (r *Reloader) Reload(ctx context.Context) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	loaded, _, err := Load(r.args)
+	if err != nil {
+		return err
+	}
+	previous := r.Current()
+	next := *previous
+	next.Log, next.Cors, next.Features = loaded.Log, loaded.Cors, loaded.Features
+	if !reflect.DeepEqual(next.RateLimit, loaded.RateLimit) {
+		// buckets of the limiter are reset for new rules, so rules are only replaced when they have changed
+		next.RateLimit, next.rateLimits = loaded.RateLimit, loaded.rateLimits
+	}
+
+	applied, restart := changes(previous, &next), changes(&next, loaded)
+	if len(restart) > 0 {
+		logger.Warnf("configuration changes are applied on restart: %s", strings.Join(restart, ", "))
+	}
+	if len(applied) == 0 {
+		logger.Info("configuration reloaded, nothing has changed")
+		return nil
+	}
+	var prepared []*ReloadChange
+	for _, handler := range r.handlers {
+		change, herror := handler(previous, &next)
+		if herror != nil {
+			for _, discarded := range prepared {
+				if discarded.Discard != nil {
+					discarded.Discard()
+				}
+			}
+			return herror
+		}
+		if change != nil {
+			prepared = append(prepared, change)
+		}
+	}
+	r.current.Store(&next)
+	for _, change := range prepared {
+		if change.Apply != nil {
+			change.Apply()
+		}
+	}
+	logger.Infof("configuration reloaded, changed: %s", strings.Join(applied, ", "))
+	return nil
+}
+
+// function to reload the configuration on SIGHUP and on changes of the config files, until the context is done
+func (r *Reloader) Watch(ctx context.Context) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// directories are watched, as files are replaced instead of written by editors and config maps
+	files := map[string]bool{}
+	for _, file := range r.Current().files {
+		path, aerror := filepath.Abs(file)
+		if aerror != nil {
+			watcher.Close()
+			return aerror
+		}
+		files[path] = true
+		if werror := watcher.Add(filepath.Dir(path)); werror != nil {
+			watcher.Close()
+			return fmt.Errorf("cannot watch %s: %w", filepath.Dir(path), werror)
+		}
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(signals)
+		timer := time.NewTimer(reloadDelay)
+		timer.Stop()
+		reload := func(reason string) {
+			logger.Infof("reloading configuration, reason: %s", reason)
+			if rerror := r.Reload(ctx); rerror != nil {
+				logger.Errorf("configuration is not reloaded, current configuration is kept, error: %v", rerror)
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				reload("SIGHUP")
+			case event := <-watcher.Events:
+				if path, _ := filepath.Abs(event.Name); files[path] && !event.Has(fsnotify.Chmod) {
+					timer.Reset(reloadDelay)
+				}
+			case <-timer.C:
+				reload("config file changed")
+			case werror := <-watcher.Errors:
+				logger.Warnf("error while watching config files: %v", werror)
+			}
+		}
+	}()
+	return nil
+}
+
+// function to get the changes of the values, e.g. "log.level: info -> debug", secrets are redacted
+func changes(previous *Config, next *Config) []string {
+	var changed []string
+	nextValue := reflect.ValueOf(next).Elem()
+	walkFields(reflect.ValueOf(previous).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		nextField := fieldByPath(nextValue, path)
+		if reflect.DeepEqual(value.Interface(), nextField.Interface()) {
+			return
+		}
+		secret := field.Tag.Get("secret")
+		changed = append(changed, fmt.Sprintf("%s: %s -> %s", path, formatValue(value, secret), formatValue(nextField, secret)))
+	})
+	return changed
+}
+
+// function to get the value of the yaml path, e.g. "log.file.path"
+func fieldByPath(value reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		for i := 0; i < value.NumField(); i++ {
+			if tag, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ","); tag == name {
+				value = value.Field(i)
+				break
+			}
+		}
+	}
+	return value
+}
+
+func formatValue(value reflect.Value, secret string) string {
+	if value.Kind() == reflect.String {
+		return fmt.Sprintf("%q", redact(value.String(), secret))
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,144 @@
+package configs
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/appfeatures"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/commons/appratelimit"
+	"GolangCourse/commons/apptls"
+	"GolangCourse/commons/apptracing"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// validation of the configuration, errors name the key and the environment variable of the value
+type validation struct {
+	envs   map[string]string
+	errors []error
+}
+
+// function to validate the configuration, all invalid values are reported at once
+func (c *Config) Validate() error {
+	v := &validation{envs: map[string]string{}}
+	walkFields(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		v.envs[path] = field.Tag.Get("env")
+	})
+
+	v.check(slices.Contains([]string{ProfileDev, ProfileStaging, ProfileProd}, c.Profile), "profile", "'%s' is not a profile, expected dev, staging or prod", c.Profile)
+	v.check(c.Http.Port > 0 && c.Http.Port <= 65535, "http.port", "must be between 1 and 65535")
+	v.check(c.Http.ReadHeaderTimeout >= 0, "http.read_header_timeout", "must not be negative, 0 disables the timeout")
+	v.check(c.Http.ReadTimeout >= 0, "http.read_timeout", "must not be negative, 0 disables the timeout")
+	v.check(c.Http.WriteTimeout >= 0, "http.write_timeout", "must not be negative, 0 disables the timeout")
+	v.check(c.Http.IdleTimeout >= 0, "http.idle_timeout", "must not be negative, 0 disables the timeout")
+	v.check(c.Http.RequestTimeout >= 0, "http.request_timeout", "must not be negative, 0 disables the deadline")
+	v.check(c.Http.ShutdownTimeout > 0, "http.shutdown_timeout", "must be positive")
+	v.check(c.Http.MaxHeaderBytes > 0, "http.max_header_bytes", "must be positive")
+	if _, err := parseSize(c.Http.MaxBodySize); err != nil {
+		v.check(false, "http.max_body_size", "%v", err)
+	}
+	if _, err := parseRouteValues(c.Http.RouteBodySizes, parseSize); err != nil {
+		v.check(false, "http.route_body_sizes", "%v", err)
+	}
+	if _, err := parseRouteValues(c.Http.RouteTimeouts, time.ParseDuration); err != nil {
+		v.check(false, "http.route_timeouts", "%v", err)
+	}
+
+	c.validateTls(v)
+
+	v.check(len(c.Mongo.Uri) > 0, "mongo.uri", "is required")
+	v.check(len(c.Mongo.Database) > 0, "mongo.database", "is required")
+	v.check(c.Mongo.ConnectTimeout > 0, "mongo.connect_timeout", "must be positive")
+
+	v.check(c.Auth.Jwt.Leeway >= 0, "auth.jwt.leeway", "must not be negative")
+	v.check(c.Auth.AccessTokenTtl > 0, "auth.access_token_ttl", "must be positive")
+	v.check(c.Auth.RefreshTokenTtl > 0, "auth.refresh_token_ttl", "must be positive")
+	v.check(c.Auth.LockoutThreshold >= 0, "auth.lockout_threshold", "must not be negative, 0 disables the lockout")
+	v.check(c.Auth.LockoutDuration >= 0, "auth.lockout_duration", "must not be negative")
+	v.check(c.Password.MinLength > 0, "password.min_length", "must be positive")
+	v.check(c.Password.MinClasses >= 1 && c.Password.MinClasses <= 4, "password.min_classes", "must be between 1 and 4")
+
+	if _, err := appratelimit.ParseConfig(c.RateLimit.Default, c.RateLimit.Routes, ""); err != nil {
+		v.check(false, "rate_limit.routes", "%v", err)
+	}
+	if len(strings.TrimSpace(c.RateLimit.ClientIp)) > 0 {
+		_, err := appratelimit.ParseRule(c.RateLimit.ClientIp)
+		v.check(err == nil, "rate_limit.client_ip", "%v", err)
+	}
+	v.check(c.AccessLog.SampleRate >= 0 && c.AccessLog.SampleRate <= 1, "access_log.sample_rate", "must be between 0 and 1")
+
+	if _, err := zapcore.ParseLevel(c.Log.Level); err != nil {
+		v.check(false, "log.level", "'%s' is not a level, expected debug, info, warn or error", c.Log.Level)
+	}
+	v.check(slices.Contains([]string{"console", "json"}, strings.ToLower(c.Log.Format)), "log.format", "'%s' is not a format, expected console or json", c.Log.Format)
+	v.check(len(c.Log.Outputs) > 0, "log.outputs", "at least one output is required")
+	v.check(c.Log.RecentSize >= 0, "log.recent_size", "must not be negative, 0 disables the recent logs")
+	v.check(slices.Contains([]string{apploggers.RedactionMask, apploggers.RedactionHash, apploggers.RedactionOff}, c.Log.Redaction.Mode),
+		"log.redaction.mode", "'%s' is not a mode, expected mask, hash or off", c.Log.Redaction.Mode)
+	// hashes without key are reversed by hashing guessed emails and phone numbers
+	v.check(c.Log.Redaction.Mode != apploggers.RedactionHash || len(c.Log.Redaction.HashKey) >= apploggers.MinRedactionHashKeyLength,
+		"log.redaction.hash_key", "at least %d bytes are required by hash redaction", apploggers.MinRedactionHashKeyLength)
+
+	v.check(slices.Contains([]string{apptracing.ExporterNone, apptracing.ExporterOtlp, apptracing.ExporterStdout, apptracing.ExporterFile}, c.Tracing.Exporter),
+		"tracing.exporter", "'%s' is not an exporter, expected none, otlp, stdout or file", c.Tracing.Exporter)
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	for _, origin := range c.Cors.AllowOrigins {
+		parsed, err := url.Parse(origin)
+		valid := origin == "*" || (err == nil && len(parsed.Scheme) > 0 && len(parsed.Host) > 0 && len(parsed.Path) == 0)
+		v.check(valid, "cors.allow_origins", "'%s' is not an origin, expected * or <scheme>://<host>[:<port>]", origin)
+	}
+	for name := range c.Features {
+		v.check(appfeatures.Known(name), "features", "'%s' is not a feature, expected %s", name, strings.Join(appfeatures.Names(), ", "))
+	}
+
+	// unprotected api and plain personal data in logs are only acceptable on developer machines
+	v.check(!c.Auth.Disabled || c.Profile == ProfileDev, "auth.disabled", "authentication can only be disabled in dev profile")
+	if c.Profile == ProfileProd {
+		v.check(c.Log.Redaction.Mode != apploggers.RedactionOff, "log.redaction.mode", "redaction cannot be disabled in prod profile")
+	}
+
+	if len(v.errors) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errors...))
+	}
+	return nil
+}
+
+// function to validate the https server, client certificates require https and a ca bundle
+func (c *Config) validateTls(v *validation) {
+	https := len(c.Tls.CertFile) > 0
+	v.check(https == (len(c.Tls.KeyFile) > 0), "tls.key_file", "certificate and key are required together")
+	v.check(slices.Contains([]string{apptls.ClientAuthNone, apptls.ClientAuthRequest, apptls.ClientAuthRequire}, c.Tls.ClientAuth),
+		"tls.client_auth", "'%s' is not a mode, expected none, request or require", c.Tls.ClientAuth)
+	clientCertificates := c.Tls.ClientAuth == apptls.ClientAuthRequest || c.Tls.ClientAuth == apptls.ClientAuthRequire
+	v.check(!clientCertificates || https, "tls.client_auth", "client certificates require tls.cert_file")
+	v.check(!clientCertificates || len(c.Tls.ClientCaFile) > 0, "tls.client_ca_file", "is required to verify client certificates")
+	if _, err := appauth.NewCertificateAuthenticator(c.Tls.ClientPrincipals); err != nil {
+		v.check(false, "tls.client_principals", "%v", err)
+	}
+	for _, network := range c.Tls.MtlsNetworks {
+		_, err := netip.ParsePrefix(network)
+		v.check(err == nil, "tls.mtls_networks", "'%s' is not a network, expected e.g. 10.0.0.0/8", network)
+	}
+	v.check(len(c.Tls.MtlsNetworks) == 0 || clientCertificates, "tls.mtls_networks", "client certificates require tls.client_auth request or require")
+	v.check(c.Tls.ReloadInterval >= 0, "tls.reload_interval", "must not be negative, 0 disables the reload")
+}
+
+// function to record an error when the condition is not met, e.g. "http.port (HTTP_PORT): must be between 1 and 65535"
+func (v *validation) check(ok bool, path string, format string, args ...any) {
+	if ok {
+		return
+	}
+	name := path
+	if env := v.envs[path]; len(env) > 0 {
+		name = fmt.Sprintf("%s (%s)", path, env)
+	}
+	v.errors = append(v.errors, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"GolangCourse/commons/appdb"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/configs"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type akdbservice struct {
+	akcollection appdb.DatabaseCollection
+}
+
+type ApiKeyDbService interface {
+	GetApiKeys(ctx context.Context) ([]*models.ApiKey, error)
+	GetApiKeyByPrefix(ctx context.Context, prefix string) (*models.ApiKey, error)
+	SaveApiKey(ctx context.Context, apiKey *dbmodel.ApiKeySchema) (string, error)
+	RevokeApiKey(ctx context.Context, apiKeyId string, revokedAt time.Time) error
+	UpdateLastUsed(ctx context.Context, apiKeyId primitive.ObjectID, usedAt time.Time, interval time.Duration) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+func NewApiKeyDbService(dbclient appdb.DatabaseClient) ApiKeyDbService {
+	return &akdbservice{
+		akcollection: dbclient.Collection(configs.MONGO_API_KEYS_COLLECTION),
+	}
+}
+
+// function to get all api keys, including revoked and expired keys
+func (a *akdbservice) GetApiKeys(ctx context.Context) ([]*models.ApiKey, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing GetApiKeys")
+	var apiKeys []*models.ApiKey
+	dbError := a.akcollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}), &apiKeys)
+	if dbError != nil {
+		logger.Error(dbError)
+		return nil, apperrors.FromDbError(dbError, "cannot get api keys")
+	}
+	logger.Infof("Executed GetApiKeys, api keys: %d", len(apiKeys))
+	return apiKeys, nil
+}
+
+func (a *akdbservice) GetApiKeyByPrefix(ctx context.Context, prefix string) (*models.ApiKey, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing GetApiKeyByPrefix, prefix: %s", prefix)
+	var apiKey *models.ApiKey
+	dbError := a.akcollection.FindOne(ctx, bson.M{"prefix": prefix}, &apiKey)
+	if dbError != nil {
+		logger.Error(dbError)
+		return nil, apperrors.FromDbError(dbError, "cannot get api key")
+	}
+	logger.Infof("Executed GetApiKeyByPrefix, apiKeyId: %s", apiKey.Id.Hex())
+	return apiKey, nil
+}
+
+func (a *akdbservice) SaveApiKey(ctx context.Context, apiKey *dbmodel.ApiKeySchema) (string, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing SaveApiKey, prefix: %s", apiKey.Prefix)
+	result, dbError := a.akcollection.InsertOne(ctx, apiKey)
+	if dbError != nil {
+		logger.Error(dbError)
+		return "", apperrors.FromDbError(dbError, "cannot save api key")
+	}
+	id := result.InsertedID.(primitive.ObjectID).Hex()
+	logger.Infof("Executed SaveApiKey, apiKeyId: %s", id)
+	return id, nil
+}
+
+// function to revoke api key, revoked keys are kept for auditing
+func (a *akdbservice) RevokeApiKey(ctx context.Context, apiKeyId string, revokedAt time.Time) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing RevokeApiKey, apiKeyId: %s", apiKeyId)
+	id, err := primitive.ObjectIDFromHex(apiKeyId)
+	if err != nil {
+		return apperrors.NewInvalidArgument(fmt.Sprintf("invalid api key id provided, apiKeyId: %s", apiKeyId))
+	}
+	var filter = bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}}
+	result, dbError := a.akcollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": revokedAt}})
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot revoke api key")
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.NewNotFound("cannot revoke api key, not found or already revoked")
+	}
+	logger.Infof("Executed RevokeApiKey, apiKeyId: %s", apiKeyId)
+	return nil
+}
+
+// function to track last use of api key, the key is only updated once per interval to limit writes
+func (a *akdbservice) UpdateLastUsed(ctx context.Context, apiKeyId primitive.ObjectID, usedAt time.Time, interval time.Duration) error {
+	var filter = bson.M{"_id": apiKeyId, "last_used_at": bson.M{"$not": bson.M{"$gt": usedAt.Add(-interval)}}}
+	_, dbError := a.akcollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	if dbError != nil {
+		return apperrors.FromDbError(dbError, "cannot update api key last used time")
+	}
+	return nil
+}
+
+// function to create indexes of api keys collection, keys are looked up by unique prefix
+func (a *akdbservice) EnsureIndexes(ctx context.Context) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing EnsureIndexes")
+	name, dbError := a.akcollection.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "prefix", Value: 1}},
+		Options: options.Index().SetName("prefix_unique").SetUnique(true),
+	})
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot create api keys prefix index")
+	}
+	logger.Infof("Executed EnsureIndexes, index: %s", name)
+	return nil
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"GolangCourse/commons/appdb"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/configs"
+	dbmodel "GolangCourse/internals/db/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type cdbservice struct {
+	ccollection appdb.DatabaseCollection
+}
+
+type CredentialDbService interface {
+	GetCredential(ctx context.Context, userId primitive.ObjectID) (*dbmodel.CredentialSchema, error)
+	SavePassword(ctx context.Context, userId primitive.ObjectID, hash string, updatedAt time.Time) error
+	RecordFailedLogin(ctx context.Context, userId primitive.ObjectID, threshold int, lockedUntil time.Time) error
+	ResetFailedLogins(ctx context.Context, userId primitive.ObjectID) error
+	SavePendingTotp(ctx context.Context, userId primitive.ObjectID, secret string) error
+	EnableTotp(ctx context.Context, userId primitive.ObjectID, secret string, step int64, recoveryCodes []string) error
+	UseTotpStep(ctx context.Context, userId primitive.ObjectID, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, hash string) (bool, error)
+	ResetTotp(ctx context.Context, userId primitive.ObjectID) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+func NewCredentialDbService(dbclient appdb.DatabaseClient) CredentialDbService {
+	return &cdbservice{
+		ccollection: dbclient.Collection(configs.MONGO_CREDENTIALS_COLLECTION),
+	}
+}
+
+func (c *cdbservice) GetCredential(ctx context.Context, userId primitive.ObjectID) (*dbmodel.CredentialSchema, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing GetCredential, userId: %s", userId.Hex())
+	var credential *dbmodel.CredentialSchema
+	dbError := c.ccollection.FindOne(ctx, bson.M{"user_id": userId}, &credential)
+	if dbError != nil {
+		logger.Error(dbError)
+		return nil, apperrors.FromDbError(dbError, "cannot get credential")
+	}
+	logger.Infof("Executed GetCredential, userId: %s", userId.Hex())
+	return credential, nil
+}
+
+// function to set password hash of user, failed logins and lockout are reset
+func (c *cdbservice) SavePassword(ctx context.Context, userId primitive.ObjectID, hash string, updatedAt time.Time) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing SavePassword, userId: %s", userId.Hex())
+	update := bson.M{
+		"$set":   bson.M{"hash": hash, "failed_attempts": 0, "updated_at": updatedAt},
+		"$unset": bson.M{"locked_until": ""},
+	}
+	_, dbError := c.ccollection.UpdateOne(ctx, bson.M{"user_id": userId}, update, options.Update().SetUpsert(true))
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot save password")
+	}
+	logger.Infof("Executed SavePassword, userId: %s", userId.Hex())
+	return nil
+}
+
+// function to count failed login, the credential is locked when the failures reach the threshold
+// counting and locking is a single update, so that concurrent failures are not lost, threshold 0 disables the lockout
+func (c *cdbservice) RecordFailedLogin(ctx context.Context, userId primitive.ObjectID, threshold int, lockedUntil time.Time) error {
+	if threshold <= 0 {
+		return nil
+	}
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing RecordFailedLogin, userId: %s", userId.Hex())
+	reached := bson.M{"$gte": bson.A{"$failed_attempts", threshold}}
+	update := bson.A{
+		bson.M{"$set": bson.M{"failed_attempts": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failed_attempts", 0}}, 1}}}},
+		bson.M{"$set": bson.M{
+			"locked_until":    bson.M{"$cond": bson.A{reached, lockedUntil, "$locked_until"}},
+			"failed_attempts": bson.M{"$cond": bson.A{reached, 0, "$failed_attempts"}},
+		}},
+	}
+	_, dbError := c.ccollection.UpdateOne(ctx, bson.M{"user_id": userId}, update)
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot record failed login")
+	}
+	logger.Infof("Executed RecordFailedLogin, userId: %s", userId.Hex())
+	return nil
+}
+
+func (c *cdbservice) ResetFailedLogins(ctx context.Context, userId primitive.ObjectID) error {
+	var filter = bson.M{"user_id": userId, "failed_attempts": bson.M{"$gt": 0}}
+	update := bson.M{"$set": bson.M{"failed_attempts": 0}, "$unset": bson.M{"locked_until": ""}}
+	_, dbError := c.ccollection.UpdateOne(ctx, filter, update)
+	if dbError != nil {
+		return apperrors.FromDbError(dbError, "cannot reset failed logins")
+	}
+	return nil
+}
+
+// function to save totp secret which is not verified yet, a previous pending secret is replaced
+func (c *cdbservice) SavePendingTotp(ctx context.Context, userId primitive.ObjectID, secret string) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing SavePendingTotp, userId: %s", userId.Hex())
+	result, dbError := c.ccollection.UpdateOne(ctx, bson.M{"user_id": userId}, bson.M{"$set": bson.M{"totp_pending_secret": secret}})
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot save totp secret")
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.NewNotFound("cannot save totp secret, credential not found")
+	}
+	logger.Infof("Executed SavePendingTotp, userId: %s", userId.Hex())
+	return nil
+}
+
+// function to enable the pending totp secret, recovery codes are replaced
+func (c *cdbservice) EnableTotp(ctx context.Context, userId primitive.ObjectID, secret string, step int64, recoveryCodes []string) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing EnableTotp, userId: %s", userId.Hex())
+	var filter = bson.M{"user_id": userId, "totp_pending_secret": secret}
+	update := bson.M{
+		"$set":   bson.M{"totp_enabled": true, "totp_secret": secret, "totp_last_step": step, "recovery_codes": recoveryCodes},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	}
+	result, dbError := c.ccollection.UpdateOne(ctx, filter, update)
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot enable totp")
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.NewConflict("cannot enable totp, enrollment was restarted")
+	}
+	logger.Infof("Executed EnableTotp, userId: %s", userId.Hex())
+	return nil
+}
+
+// function to mark totp time step as used, false is returned when the step or a later step was used already
+func (c *cdbservice) UseTotpStep(ctx context.Context, userId primitive.ObjectID, step int64) (bool, error) {
+	var filter = bson.M{"user_id": userId, "totp_last_step": bson.M{"$not": bson.M{"$gte": step}}}
+	result, dbError := c.ccollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_last_step": step}})
+	if dbError != nil {
+		return false, apperrors.FromDbError(dbError, "cannot use totp code")
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// function to consume recovery code by its hash, false is returned for unknown or used codes
+func (c *cdbservice) UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, hash string) (bool, error) {
+	var filter = bson.M{"user_id": userId, "recovery_codes": hash}
+	result, dbError := c.ccollection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recovery_codes": hash}})
+	if dbError != nil {
+		return false, apperrors.FromDbError(dbError, "cannot use recovery code")
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// function to remove totp second factor and recovery codes of user
+func (c *cdbservice) ResetTotp(ctx context.Context, userId primitive.ObjectID) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing ResetTotp, userId: %s", userId.Hex())
+	update := bson.M{"$unset": bson.M{
+		"totp_enabled": "", "totp_secret": "", "totp_pending_secret": "", "totp_last_step": "", "recovery_codes": "",
+	}}
+	result, dbError := c.ccollection.UpdateOne(ctx, bson.M{"user_id": userId}, update)
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot reset totp")
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.NewNotFound("cannot reset totp, credential not found")
+	}
+	logger.Infof("Executed ResetTotp, userId: %s", userId.Hex())
+	return nil
+}
+
+func (c *cdbservice) EnsureIndexes(ctx context.Context) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing EnsureIndexes")
+	name, dbError := c.ccollection.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("user_id_unique").SetUnique(true),
+	})
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot create credentials user index")
+	}
+	logger.Infof("Executed EnsureIndexes, index: %s", name)
+	return nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"GolangCourse/commons/appdb"
+	"GolangCourse/commons/apploggers"
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collection of tests which records the updates, updates match a single document
+type recordingCollection struct {
+	appdb.DatabaseCollection
+	filters []interface{}
+	updates []interface{}
+}
+
+func (r *recordingCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	r.filters = append(r.filters, filter)
+	r.updates = append(r.updates, update)
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	ctx, _ := apploggers.NewLogger()
+	userId := primitive.NewObjectID()
+	lockedUntil := time.Now().Add(time.Hour)
+	tests := []struct {
+		name      string
+		threshold int
+		updates   int
+	}{
+		{"threshold 0 disables the lockout", 0, 0},
+		{"negative threshold disables the lockout", -1, 0},
+		{"threshold locks at the failures", 3, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			collection := &recordingCollection{}
+			service := &cdbservice{ccollection: collection}
+			if err := service.RecordFailedLogin(ctx, userId, test.threshold, lockedUntil); err != nil {
+				t.Fatal(err)
+			}
+			if len(collection.updates) != test.updates {
+				t.Fatalf("expected %d updates, got %d", test.updates, len(collection.updates))
+			}
+			if test.updates == 0 {
+				return
+			}
+			// failures are counted and compared with the threshold in a single update
+			pipeline := collection.updates[0].(bson.A)
+			lock := pipeline[1].(bson.M)["$set"].(bson.M)["locked_until"].(bson.M)["$cond"].(bson.A)
+			reached := lock[0].(bson.M)["$gte"].(bson.A)
+			if reached[1] != test.threshold || lock[1] != lockedUntil {
+				t.Fatalf("unexpected lock condition: %v", lock)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type ApiKeySchema struct {
+	Name      string     `bson:"name"`
+	Prefix    string     `bson:"prefix"`
+	Hash      string     `bson:"hash"`
+	Scopes    []string   `bson:"scopes"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty"`
+	CreatedBy string     `bson:"created_by,omitempty"`
+	CreatedAt time.Time  `bson:"created_at"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// result of a bulk upsert, failures are keyed by index of the document in the batch
+type BulkUpsertResult struct {
+	Inserted int64
+	Updated  int64
+	Failures map[int]string
+}
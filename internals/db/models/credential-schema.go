@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// password credential of a user, stored separately from the user profile
+type CredentialSchema struct {
+	UserId         primitive.ObjectID `bson:"user_id"`
+	Hash           string             `bson:"hash"`
+	FailedAttempts int                `bson:"failed_attempts"`
+	LockedUntil    *time.Time         `bson:"locked_until,omitempty"`
+	UpdatedAt      time.Time          `bson:"updated_at"`
+
+	// totp second factor, the pending secret is enabled once a code of it is verified
+	TotpEnabled       bool     `bson:"totp_enabled,omitempty"`
+	TotpSecret        string   `bson:"totp_secret,omitempty"`
+	TotpPendingSecret string   `bson:"totp_pending_secret,omitempty"`
+	TotpLastStep      int64    `bson:"totp_last_step,omitempty"`
+	RecoveryCodes     []string `bson:"recovery_codes,omitempty"`
+}
+
+// refresh token of a user, only the hash of the token is stored
+type RefreshTokenSchema struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty"`
+	UserId    primitive.ObjectID `bson:"user_id"`
+	Hash      string             `bson:"hash"`
+	Amr       []string           `bson:"amr"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// number of users of a type and active state
+type UserCount struct {
+	Type     string `bson:"type"`
+	IsActive bool   `bson:"is_active"`
+	Count    int64  `bson:"count"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// user of a bulk upsert, fields are the json names of the values provided for the user
+// only provided fields are set on existing users, the other fields are only set on insert
+// users which are update only are not inserted, e.g. when the provided fields are not valid for a new user
+type UserUpsert struct {
+	User       *UserSchema
+	Fields     []string
+	UpdateOnly bool
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"GolangCourse/commons/appdb"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/configs"
+	dbmodel "GolangCourse/internals/db/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type rtdbservice struct {
+	rtcollection appdb.DatabaseCollection
+}
+
+type RefreshTokenDbService interface {
+	GetRefreshToken(ctx context.Context, hash string) (*dbmodel.RefreshTokenSchema, error)
+	SaveRefreshToken(ctx context.Context, token *dbmodel.RefreshTokenSchema) error
+	RevokeRefreshToken(ctx context.Context, tokenId primitive.ObjectID, revokedAt time.Time) (bool, error)
+	RevokeUserRefreshTokens(ctx context.Context, userId primitive.ObjectID, revokedAt time.Time) (int64, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+func NewRefreshTokenDbService(dbclient appdb.DatabaseClient) RefreshTokenDbService {
+	return &rtdbservice{
+		rtcollection: dbclient.Collection(configs.MONGO_REFRESH_TOKENS_COLLECTION),
+	}
+}
+
+func (r *rtdbservice) GetRefreshToken(ctx context.Context, hash string) (*dbmodel.RefreshTokenSchema, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing GetRefreshToken")
+	var token *dbmodel.RefreshTokenSchema
+	dbError := r.rtcollection.FindOne(ctx, bson.M{"hash": hash}, &token)
+	if dbError != nil {
+		logger.Error(dbError)
+		return nil, apperrors.FromDbError(dbError, "cannot get refresh token")
+	}
+	logger.Infof("Executed GetRefreshToken, userId: %s", token.UserId.Hex())
+	return token, nil
+}
+
+func (r *rtdbservice) SaveRefreshToken(ctx context.Context, token *dbmodel.RefreshTokenSchema) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing SaveRefreshToken, userId: %s", token.UserId.Hex())
+	_, dbError := r.rtcollection.InsertOne(ctx, token)
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot save refresh token")
+	}
+	logger.Infof("Executed SaveRefreshToken, userId: %s", token.UserId.Hex())
+	return nil
+}
+
+// function to revoke refresh token, false is returned when the token was already revoked
+func (r *rtdbservice) RevokeRefreshToken(ctx context.Context, tokenId primitive.ObjectID, revokedAt time.Time) (bool, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing RevokeRefreshToken, tokenId: %s", tokenId.Hex())
+	var filter = bson.M{"_id": tokenId, "revoked_at": bson.M{"$exists": false}}
+	result, dbError := r.rtcollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": revokedAt}})
+	if dbError != nil {
+		logger.Error(dbError)
+		return false, apperrors.FromDbError(dbError, "cannot revoke refresh token")
+	}
+	logger.Infof("Executed RevokeRefreshToken, tokenId: %s, revoked: %v", tokenId.Hex(), result.ModifiedCount > 0)
+	return result.ModifiedCount > 0, nil
+}
+
+// function to revoke all active refresh tokens of the user
+func (r *rtdbservice) RevokeUserRefreshTokens(ctx context.Context, userId primitive.ObjectID, revokedAt time.Time) (int64, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing RevokeUserRefreshTokens, userId: %s", userId.Hex())
+	var filter = bson.M{"user_id": userId, "revoked_at": bson.M{"$exists": false}}
+	result, dbError := r.rtcollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": revokedAt}})
+	if dbError != nil {
+		logger.Error(dbError)
+		return 0, apperrors.FromDbError(dbError, "cannot revoke refresh tokens")
+	}
+	logger.Infof("Executed RevokeUserRefreshTokens, userId: %s, revoked: %d", userId.Hex(), result.ModifiedCount)
+	return result.ModifiedCount, nil
+}
+
+// function to create indexes of refresh tokens, tokens are looked up by hash and removed by mongo after expiry
+func (r *rtdbservice) EnsureIndexes(ctx context.Context) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing EnsureIndexes")
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetName("hash_unique").SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetName("user_id")},
+	}
+	for _, index := range indexes {
+		name, dbError := r.rtcollection.CreateIndex(ctx, index)
+		if dbError != nil {
+			logger.Error(dbError)
+			return apperrors.FromDbError(dbError, "cannot create refresh tokens indexes")
+		}
+		logger.Infof("Executed EnsureIndexes, index: %s", name)
+	}
+	return nil
+}
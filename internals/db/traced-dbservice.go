@@ -0,0 +1,107 @@
+package db
+
+import (
+	"GolangCourse/commons/apptracing"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// user db service which records a span of every operation
+type tdbservice struct {
+	delegate DbService
+}
+
+func newTracedDbService(delegate DbService) DbService {
+	return &tdbservice{delegate: delegate}
+}
+
+func startDbSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return apptracing.Start(ctx, "DbService."+operation, trace.SpanKindInternal)
+}
+
+func (t *tdbservice) GetUserById(ctx context.Context, id string, fields []string) (*models.User, error) {
+	ctx, span := startDbSpan(ctx, "GetUserById")
+	user, err := t.delegate.GetUserById(ctx, id, fields)
+	apptracing.End(span, err)
+	return user, err
+}
+
+func (t *tdbservice) GetUserByEmail(ctx context.Context, email string, fields []string) (*models.User, error) {
+	ctx, span := startDbSpan(ctx, "GetUserByEmail")
+	user, err := t.delegate.GetUserByEmail(ctx, email, fields)
+	apptracing.End(span, err)
+	return user, err
+}
+
+func (t *tdbservice) DeleteUserById(ctx context.Context, id string) error {
+	ctx, span := startDbSpan(ctx, "DeleteUserById")
+	err := t.delegate.DeleteUserById(ctx, id)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *tdbservice) GetUsers(ctx context.Context, filter *models.UserFilter, fields []string) ([]*models.User, error) {
+	ctx, span := startDbSpan(ctx, "GetUsers")
+	users, err := t.delegate.GetUsers(ctx, filter, fields)
+	apptracing.End(span, err)
+	return users, err
+}
+
+func (t *tdbservice) GetUsersByEmails(ctx context.Context, emails []string, fields []string) ([]*models.User, error) {
+	ctx, span := startDbSpan(ctx, "GetUsersByEmails")
+	users, err := t.delegate.GetUsersByEmails(ctx, emails, fields)
+	apptracing.End(span, err)
+	return users, err
+}
+
+func (t *tdbservice) StreamUsers(ctx context.Context, filter *models.UserFilter, fields []string, handler func(user *models.User) error) error {
+	ctx, span := startDbSpan(ctx, "StreamUsers")
+	err := t.delegate.StreamUsers(ctx, filter, fields, handler)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *tdbservice) SaveUser(ctx context.Context, user *dbmodel.UserSchema) (string, error) {
+	ctx, span := startDbSpan(ctx, "SaveUser")
+	id, err := t.delegate.SaveUser(ctx, user)
+	apptracing.End(span, err)
+	return id, err
+}
+
+func (t *tdbservice) UpdateUser(ctx context.Context, user *dbmodel.UserSchema, userId string) error {
+	ctx, span := startDbSpan(ctx, "UpdateUser")
+	err := t.delegate.UpdateUser(ctx, user, userId)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *tdbservice) UpdateUserFields(ctx context.Context, userId string, fields map[string]interface{}) error {
+	ctx, span := startDbSpan(ctx, "UpdateUserFields")
+	err := t.delegate.UpdateUserFields(ctx, userId, fields)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *tdbservice) UpsertUsersByEmail(ctx context.Context, users []*dbmodel.UserUpsert) (*dbmodel.BulkUpsertResult, error) {
+	ctx, span := startDbSpan(ctx, "UpsertUsersByEmail")
+	result, err := t.delegate.UpsertUsersByEmail(ctx, users)
+	apptracing.End(span, err)
+	return result, err
+}
+
+func (t *tdbservice) CountUsers(ctx context.Context) ([]*dbmodel.UserCount, error) {
+	ctx, span := startDbSpan(ctx, "CountUsers")
+	counts, err := t.delegate.CountUsers(ctx)
+	apptracing.End(span, err)
+	return counts, err
+}
+
+func (t *tdbservice) EnsureIndexes(ctx context.Context) error {
+	ctx, span := startDbSpan(ctx, "EnsureIndexes")
+	err := t.delegate.EnsureIndexes(ctx)
+	apptracing.End(span, err)
+	return err
+}
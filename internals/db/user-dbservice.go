@@ -1,17 +1,21 @@
 package db
 
 import (
-	"GolangCourse/commons"
 	"GolangCourse/commons/appdb"
+	"GolangCourse/commons/apperrors"
 	"GolangCourse/commons/apploggers"
 	"GolangCourse/configs"
 	dbmodel "GolangCourse/internals/db/models"
 	"GolangCourse/internals/models"
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -20,35 +24,59 @@ type udbservice struct {
 }
 
 type DbService interface {
-	GetUserById(ctx context.Context, id string) (*models.User, error)
+	GetUserById(ctx context.Context, id string, fields []string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string, fields []string) (*models.User, error)
 	DeleteUserById(ctx context.Context, id string) error
-	GetUsers(ctx context.Context) ([]*models.User, error)
+	GetUsers(ctx context.Context, filter *models.UserFilter, fields []string) ([]*models.User, error)
+	GetUsersByEmails(ctx context.Context, emails []string, fields []string) ([]*models.User, error)
+	StreamUsers(ctx context.Context, filter *models.UserFilter, fields []string, handler func(user *models.User) error) error
 	SaveUser(ctx context.Context, user *dbmodel.UserSchema) (string, error)
 	UpdateUser(ctx context.Context, user *dbmodel.UserSchema, userId string) error
+	UpdateUserFields(ctx context.Context, userId string, fields map[string]interface{}) error
+	UpsertUsersByEmail(ctx context.Context, users []*dbmodel.UserUpsert) (*dbmodel.BulkUpsertResult, error)
+	CountUsers(ctx context.Context) ([]*dbmodel.UserCount, error)
+	EnsureIndexes(ctx context.Context) error
 }
 
+// function to create user db service, operations of the service are traced
 func NewUserDbService(dbclient appdb.DatabaseClient) DbService {
-	return &udbservice{
+	return newTracedDbService(&udbservice{
 		ucollection: dbclient.Collection(configs.MONGO_USERS_COLLECTION),
-	}
+	})
 }
 
-func (u *udbservice) GetUserById(ctx context.Context, userId string) (*models.User, error) {
+// function to get user by id, only selected fields are loaded when fields are provided
+func (u *udbservice) GetUserById(ctx context.Context, userId string, fields []string) (*models.User, error) {
 	logger := apploggers.GetLoggerWithCorrelationid(ctx)
 	logger.Infof("Executing GetUserById, Id: %s", userId)
 	// get object id from userid string
 	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		return nil, fmt.Errorf("invalid userid provided, userId: %s", userId)
+		return nil, apperrors.NewInvalidArgument(fmt.Sprintf("invalid userid provided, userId: %s", userId))
 	}
 	var user *models.User
 	var filter = bson.M{"_id": id}
-	dbError := u.ucollection.FindOne(ctx, filter, &user)
+	dbError := u.ucollection.FindOne(ctx, filter, &user, options.FindOne().SetProjection(getUserProjection(fields)))
 	if dbError != nil {
 		logger.Error(dbError)
-		return nil, dbError
+		return nil, apperrors.FromDbError(dbError, "cannot get user")
 	}
-	logger.Infof("Executed GetUserById, user: %s", commons.PrintStruct(user))
+	logger.Infof("Executed GetUserById, userId: %s", userId)
+	return user, nil
+}
+
+// function to get user by normalised email, only selected fields are loaded when fields are provided
+func (u *udbservice) GetUserByEmail(ctx context.Context, email string, fields []string) (*models.User, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing GetUserByEmail")
+	var user *models.User
+	var filter = bson.M{"email": email}
+	dbError := u.ucollection.FindOne(ctx, filter, &user, options.FindOne().SetProjection(getUserProjection(fields)).SetCollation(emailCollation))
+	if dbError != nil {
+		logger.Error(dbError)
+		return nil, apperrors.FromDbError(dbError, "cannot get user by email")
+	}
+	logger.Infof("Executed GetUserByEmail, userId: %s", user.Id.Hex())
 	return user, nil
 }
 
@@ -58,34 +86,86 @@ func (u *udbservice) DeleteUserById(ctx context.Context, userId string) error {
 	// get object id from userid string
 	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		return fmt.Errorf("cannot delete user, invalid userid provided, userId: %s", userId)
+		return apperrors.NewInvalidArgument(fmt.Sprintf("cannot delete user, invalid userid provided, userId: %s", userId))
 	}
 	var filter = bson.M{"_id": id}
-	_, dbError := u.ucollection.DeleteOne(ctx, filter)
+	result, dbError := u.ucollection.DeleteOne(ctx, filter)
 	if dbError != nil {
 		logger.Error(dbError)
-		return dbError
+		return apperrors.FromDbError(dbError, "cannot delete user")
+	}
+	if result.DeletedCount == 0 {
+		return apperrors.NewNotFound("cannot delete user, not found")
 	}
 	logger.Infof("Executed DeleteUserById, Id: %s", userId)
 	return nil
 }
 
-func (u *udbservice) GetUsers(ctx context.Context) ([]*models.User, error) {
+func (u *udbservice) GetUsers(ctx context.Context, userFilter *models.UserFilter, fields []string) ([]*models.User, error) {
 	logger := apploggers.GetLoggerWithCorrelationid(ctx)
 	logger.Infof("Executing GetUsers")
 
 	// create users payload to find data from db
 	var users []*models.User
-	var filter = map[string]interface{}{}
-	dbError := u.ucollection.Find(ctx, filter, &options.FindOptions{}, &users)
+	var filter = getUserFilterQuery(userFilter)
+	dbError := u.ucollection.Find(ctx, filter, options.Find().SetProjection(getUserProjection(fields)), &users)
 	if dbError != nil {
 		logger.Error(dbError)
-		return nil, dbError
+		return nil, apperrors.FromDbError(dbError, "cannot get users")
 	}
 	logger.Infof("Executed GetUsers, users: %d", len(users))
 	return users, nil
 }
 
+// function to get users by normalised emails, unknown emails are skipped
+func (u *udbservice) GetUsersByEmails(ctx context.Context, emails []string, fields []string) ([]*models.User, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing GetUsersByEmails, emails: %d", len(emails))
+	var users []*models.User
+	var filter = bson.M{"email": bson.M{"$in": emails}}
+	dbError := u.ucollection.Find(ctx, filter, options.Find().SetProjection(getUserProjection(fields)).SetCollation(emailCollation), &users)
+	if dbError != nil {
+		logger.Error(dbError)
+		return nil, apperrors.FromDbError(dbError, "cannot get users by email")
+	}
+	logger.Infof("Executed GetUsersByEmails, users: %d", len(users))
+	return users, nil
+}
+
+// function to stream users matching the filter from db cursor, without loading all users in memory
+// handler is called for every user, iteration stops on first handler error
+func (u *udbservice) StreamUsers(ctx context.Context, userFilter *models.UserFilter, fields []string, handler func(user *models.User) error) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing StreamUsers")
+	var filter = getUserFilterQuery(userFilter)
+	cursor, dbError := u.ucollection.FindCursor(ctx, filter, options.Find().SetBatchSize(500).SetProjection(getUserProjection(fields)))
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot get users")
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var user *models.User
+		if err := cursor.Decode(&user); err != nil {
+			logger.Error(err)
+			return apperrors.FromDbError(err, "cannot read user")
+		}
+		if err := handler(user); err != nil {
+			logger.Error(err)
+			return err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		logger.Error(err)
+		return apperrors.FromDbError(err, "cannot get users")
+	}
+	logger.Infof("Executed StreamUsers, users: %d", count)
+	return nil
+}
+
 func (u *udbservice) SaveUser(ctx context.Context, user *dbmodel.UserSchema) (string, error) {
 	logger := apploggers.GetLoggerWithCorrelationid(ctx)
 	logger.Infof("Executing SaveUser...")
@@ -94,12 +174,12 @@ func (u *udbservice) SaveUser(ctx context.Context, user *dbmodel.UserSchema) (st
 	result, dbError := u.ucollection.InsertOne(ctx, user)
 	if dbError != nil {
 		logger.Error(dbError)
-		return "", dbError
+		return "", apperrors.FromDbError(dbError, "cannot save user")
 	}
 
 	// Extract the inserted ID from the result
 	id := result.InsertedID.(primitive.ObjectID).Hex()
-	logger.Infof("Executed SaveUser, userid: %s", commons.PrintStruct(user))
+	logger.Infof("Executed SaveUser, userId: %s", id)
 	return id, nil
 }
 
@@ -109,17 +189,214 @@ func (u *udbservice) UpdateUser(ctx context.Context, user *dbmodel.UserSchema, u
 	// get object id from userid string
 	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		return fmt.Errorf("cannot delete user, invalid userid provided, userId: %s", userId)
+		return apperrors.NewInvalidArgument(fmt.Sprintf("cannot update user, invalid userid provided, userId: %s", userId))
 	}
 	var filter = bson.M{"_id": id}
 	update := bson.M{"$set": user} // Correct update document
 	// update user in db
-	_, dbError := u.ucollection.UpdateOne(ctx, filter, update)
+	result, dbError := u.ucollection.UpdateOne(ctx, filter, update)
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot update user")
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.NewNotFound("cannot update user, not found")
+	}
+
+	logger.Infof("Executed UpdateUser, userId: %s", userId)
+	return nil
+}
+
+// function to update only the provided fields of user, fields are keyed by json name
+func (u *udbservice) UpdateUserFields(ctx context.Context, userId string, fields map[string]interface{}) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing UpdateUserFields, userId: %s", userId)
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return apperrors.NewInvalidArgument(fmt.Sprintf("cannot update user, invalid userid provided, userId: %s", userId))
+	}
+	set := bson.M{}
+	for field, value := range fields {
+		dbField, ok := userDbFields[field]
+		if !ok || dbField == "_id" {
+			return apperrors.NewInvalidArgument(fmt.Sprintf("cannot update user, invalid field: %s", field))
+		}
+		set[dbField] = value
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	result, dbError := u.ucollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot update user")
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.NewNotFound("cannot update user, not found")
+	}
+	logger.Infof("Executed UpdateUserFields, userId: %s, fields: %d", userId, len(set))
+	return nil
+}
+
+// function to insert or update users in a single unordered bulk write, matching existing users by email
+// documents rejected by the database are returned as failures instead of failing the whole batch
+// fields which are not provided keep their stored values, they are only set to the values of the user on insert
+func (u *udbservice) UpsertUsersByEmail(ctx context.Context, users []*dbmodel.UserUpsert) (*dbmodel.BulkUpsertResult, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing UpsertUsersByEmail, users: %d", len(users))
+	response := &dbmodel.BulkUpsertResult{Failures: map[int]string{}}
+	if len(users) == 0 {
+		return response, nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(users))
+	for _, user := range users {
+		set, setOnInsert := bson.M{}, bson.M{}
+		for field, value := range getUserSchemaValues(user.User) {
+			if slices.Contains(user.Fields, field) {
+				set[userDbFields[field]] = value
+			} else {
+				setOnInsert[userDbFields[field]] = value
+			}
+		}
+		// email is the filter, so it is set on insert by the upsert
+		update := bson.M{}
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+		if len(setOnInsert) > 0 {
+			update["$setOnInsert"] = setOnInsert
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"email": user.User.Email}).
+			SetCollation(emailCollation).
+			SetUpdate(update).
+			SetUpsert(!user.UpdateOnly))
+	}
+	result, dbError := u.ucollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
 	if dbError != nil {
+		var bulkError mongo.BulkWriteException
+		if !errors.As(dbError, &bulkError) || bulkError.WriteConcernError != nil {
+			logger.Error(dbError)
+			return nil, apperrors.FromDbError(dbError, "cannot upsert users")
+		}
+		for _, writeError := range bulkError.WriteErrors {
+			response.Failures[writeError.Index] = writeError.Message
+		}
+	}
+	if result != nil {
+		response.Inserted = result.UpsertedCount
+		response.Updated = result.MatchedCount
+	}
+	logger.Infof("Executed UpsertUsersByEmail, inserted: %d, updated: %d, failed: %d", response.Inserted, response.Updated, len(response.Failures))
+	return response, nil
+}
+
+// function to count users by type and active state
+func (u *udbservice) CountUsers(ctx context.Context) ([]*dbmodel.UserCount, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing CountUsers")
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"type": "$type", "isactive": "$isactive"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "type": "$_id.type", "is_active": "$_id.isactive", "count": 1}}},
+	}
+	var counts []*dbmodel.UserCount
+	if dbError := u.ucollection.Aggregate(ctx, pipeline, &counts); dbError != nil {
 		logger.Error(dbError)
-		return dbError
+		return nil, apperrors.FromDbError(dbError, "cannot count users")
 	}
+	logger.Infof("Executed CountUsers, groups: %d", len(counts))
+	return counts, nil
+}
 
-	logger.Infof("Executed UpdateUser, userid: %s", commons.PrintStruct(user))
+// function to create indexes of users collection, email is unique case insensitively like NormalizeEmail
+// the index replaces the case sensitive index of previous versions, creation fails while users share an email
+func (u *udbservice) EnsureIndexes(ctx context.Context) error {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	logger.Infof("Executing EnsureIndexes")
+	name, dbError := u.ucollection.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("email_unique_ci").SetUnique(true).SetCollation(emailCollation),
+	})
+	if dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot create users email index")
+	}
+	if dbError := u.ucollection.DropIndex(ctx, "email_unique"); dbError != nil {
+		logger.Error(dbError)
+		return apperrors.FromDbError(dbError, "cannot drop case sensitive users email index")
+	}
+	logger.Infof("Executed EnsureIndexes, index: %s", name)
 	return nil
 }
+
+// function to create db query from user filter
+func getUserFilterQuery(userFilter *models.UserFilter) bson.M {
+	filter := bson.M{}
+	if userFilter == nil {
+		return filter
+	}
+	if len(userFilter.Name) > 0 {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(userFilter.Name), "$options": "i"}
+	}
+	if len(userFilter.Email) > 0 {
+		filter["email"] = userFilter.Email
+	}
+	if len(userFilter.Type) > 0 {
+		filter["type"] = userFilter.Type
+	}
+	if userFilter.IsActive != nil {
+		filter["isactive"] = *userFilter.IsActive
+	}
+	age := bson.M{}
+	if userFilter.MinAge != nil {
+		age["$gte"] = *userFilter.MinAge
+	}
+	if userFilter.MaxAge != nil {
+		age["$lte"] = *userFilter.MaxAge
+	}
+	if len(age) > 0 {
+		filter["age"] = age
+	}
+	return filter
+}
+
+// collation of emails, strength 2 compares case insensitively, so that lookups by email use the unique index
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// db field names of user, keyed by json name
+var userDbFields = map[string]string{
+	"_id":       "_id",
+	"name":      "name",
+	"email":     "email",
+	"type":      "type",
+	"age":       "age",
+	"is_active": "isactive",
+}
+
+// function to get the values of the user which are upserted, keyed by json name
+func getUserSchemaValues(user *dbmodel.UserSchema) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      user.Name,
+		"type":      user.Type,
+		"age":       user.Age,
+		"is_active": user.IsActive,
+	}
+}
+
+// function to create db projection from json field names, nil projection loads all fields
+func getUserProjection(fields []string) interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	projection := bson.M{"_id": 0}
+	for _, field := range fields {
+		if dbField, ok := userDbFields[field]; ok {
+			projection[dbField] = 1
+		}
+	}
+	return projection
+}
@@ -0,0 +1,41 @@
+package models
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,max=128"`
+	// totp code or recovery code, required for users with two-factor authentication
+	Otp string `json:"otp,omitempty" validate:"max=32"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// access and refresh tokens issued on login and refresh
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// password change, the current password is required when users change their own password
+type PasswordRequest struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	Password        string `json:"password" validate:"required"`
+}
+
+// totp enrollment, the uri is rendered as QR code by the client
+type TotpEnrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type TotpVerifyRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// recovery codes are only returned once, when totp is enabled
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// summary of the normalisation of stored emails
+// duplicates are the ids of users sharing a normalised email, keyed by the email, they are not changed
+type EmailMigrationSummary struct {
+	Total      int                 `json:"total"`
+	Normalized int                 `json:"normalized"`
+	Duplicates map[string][]string `json:"duplicates,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type LogLevels struct {
+	Level string `json:"level" example:"info"`
+	// levels of packages and their sub packages, e.g. {"GolangCourse/internals/db": "debug"}
+	Packages map[string]string `json:"packages"`
+	// time at which temporary levels are reverted
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+type SetLogLevelsRequest struct {
+	// empty level keeps the current level
+	Level string `json:"level,omitempty" validate:"omitempty,oneof=debug info warn error dpanic panic fatal"`
+	// package levels replace all current package levels, omitted packages keep them
+	Packages map[string]string `json:"packages,omitempty" validate:"omitempty,dive,keys,required,endkeys,oneof=debug info warn error dpanic panic fatal"`
+	// duration after which the levels are reverted, e.g. "15m", empty keeps the levels
+	Ttl string `json:"ttl,omitempty" example:"15m"`
+}
+
+// query of the recent logs of the instance
+type LogQuery struct {
+	CorrelationId string
+	// min level of the entries
+	Level string
+	Since *time.Time
+	Limit int
+}
+
+type LogEntry struct {
+	Time          time.Time              `json:"time"`
+	Level         string                 `json:"level" example:"info"`
+	Caller        string                 `json:"caller,omitempty"`
+	Message       string                 `json:"message"`
+	CorrelationId string                 `json:"correlation_id,omitempty"`
+	Fields        map[string]interface{} `json:"fields,omitempty"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// filters for listing and exporting users, nil or empty values are not applied
+type UserFilter struct {
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Type     string `json:"type,omitempty"`
+	IsActive *bool  `json:"is_active,omitempty"`
+	MinAge   *int   `json:"min_age,omitempty"`
+	MaxAge   *int   `json:"max_age,omitempty"`
+}
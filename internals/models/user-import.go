@@ -0,0 +1,29 @@
+package models
+
+const (
+	ImportFormatCsv    = "csv"
+	ImportFormatNdjson = "ndjson"
+)
+
+// options for importing users from a csv or ndjson source
+type ImportOptions struct {
+	Format    string            `json:"format"`
+	Mapping   map[string]string `json:"mapping"`
+	BatchSize int               `json:"batch_size"`
+}
+
+// rejected row of an import, with the reason it was rejected
+type ImportRowError struct {
+	Line   int               `json:"line"`
+	Reason string            `json:"reason"`
+	Record map[string]string `json:"record,omitempty"`
+}
+
+// summary of an import run
+type ImportSummary struct {
+	Total    int               `json:"total"`
+	Inserted int64             `json:"inserted"`
+	Updated  int64             `json:"updated"`
+	Rejected int               `json:"rejected"`
+	Errors   []*ImportRowError `json:"errors,omitempty"`
+}
@@ -0,0 +1,8 @@
+package models
+
+const (
+	// json merge patch, RFC 7396
+	MergePatchContentType = "application/merge-patch+json"
+	// json patch, RFC 6902
+	JsonPatchContentType = "application/json-patch+json"
+)
@@ -0,0 +1,11 @@
+package models
+
+// user fields which are written by clients, the id is assigned by the server and cannot be sent
+type UserRequest struct {
+	UserProperties
+}
+
+// function to get the user of the request
+func (r *UserRequest) User() *User {
+	return &User{UserProperties: r.UserProperties}
+}
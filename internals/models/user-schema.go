@@ -1,12 +1,54 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	UserTypeAdmin = "admin"
+	UserTypeUser  = "user"
+	UserTypeGuest = "guest"
+)
 
 type User struct {
-	Id       primitive.ObjectID `json:"_id" bson:"_id"`
-	Name     string             `json:"name"`
-	Email    string             `json:"email"`
-	Type     string             `json:"type"`
-	Age      int                `json:"age"`
-	IsActive bool               `json:"is_active"`
+	Id             primitive.ObjectID `json:"_id" bson:"_id"`
+	UserProperties `bson:",inline"`
+}
+
+// user fields which are written by clients, users and requests share them so that they are validated by the same rules
+type UserProperties struct {
+	Name     string `json:"name" validate:"required,notblank,max=100"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Type     string `json:"type" validate:"omitempty,oneof=admin user guest"`
+	Age      int    `json:"age" validate:"gte=0,lte=150"`
+	IsActive bool   `json:"is_active"`
+}
+
+// json names of user fields, in document order
+var UserFields = []string{"_id", "name", "email", "type", "age", "is_active"}
+
+// function to get value of user field by json name
+func (u *User) FieldValue(field string) interface{} {
+	switch field {
+	case "_id":
+		return u.Id.Hex()
+	case "name":
+		return u.Name
+	case "email":
+		return u.Email
+	case "type":
+		return u.Type
+	case "age":
+		return u.Age
+	case "is_active":
+		return u.IsActive
+	}
+	return nil
+}
+
+// function to normalise email for storage and lookup, emails are unique case insensitively
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
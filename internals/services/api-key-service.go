@@ -0,0 +1,177 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/db"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// api keys are "uk_<prefix>_<secret>", prefix identifies the key and is safe to log
+	apiKeyPrefix       = "uk_"
+	apiKeyPrefixBytes  = 6
+	apiKeySecretBytes  = 32
+	apiKeyUsedInterval = time.Minute
+)
+
+type ApiKeyService interface {
+	CreateApiKey(context context.Context, request *models.CreateApiKeyRequest) (*models.CreatedApiKey, error)
+	GetApiKeys(context context.Context) ([]*models.ApiKey, error)
+	RevokeApiKey(context context.Context, apiKeyId string) error
+	Authenticate(context context.Context, key string) (*appauth.Principal, error)
+}
+
+type akservice struct {
+	dbservice db.ApiKeyDbService
+}
+
+func NewApiKeyService(dbservice db.ApiKeyDbService) ApiKeyService {
+	return &akservice{
+		dbservice: dbservice,
+	}
+}
+
+// function to create api key, the generated key is returned only once and only its hash is stored
+func (a *akservice) CreateApiKey(context context.Context, request *models.CreateApiKeyRequest) (*models.CreatedApiKey, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing CreateApiKey, name: %s", request.Name)
+	now := time.Now().UTC()
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		return nil, apperrors.NewInvalidArgument("invalid request payload").WithDetails(map[string]interface{}{
+			"expires_at": "must be in the future",
+		})
+	}
+
+	prefix, perror := randomHex(apiKeyPrefixBytes)
+	if perror != nil {
+		return nil, apperrors.NewInternal("cannot generate api key", perror)
+	}
+	secret, serror := randomHex(apiKeySecretBytes)
+	if serror != nil {
+		return nil, apperrors.NewInternal("cannot generate api key", serror)
+	}
+	key := apiKeyPrefix + prefix + "_" + secret
+
+	scopes := slices.Clone(request.Scopes)
+	slices.Sort(scopes)
+	schema := &dbmodel.ApiKeySchema{
+		Name:      strings.TrimSpace(request.Name),
+		Prefix:    prefix,
+		Hash:      hashToken(key),
+		Scopes:    slices.Compact(scopes),
+		ExpiresAt: request.ExpiresAt,
+		CreatedAt: now,
+	}
+	if principal := appauth.GetPrincipal(context); principal != nil {
+		schema.CreatedBy = principal.String()
+	}
+	id, dberror := a.dbservice.SaveApiKey(context, schema)
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	objectId, _ := primitive.ObjectIDFromHex(id)
+	logger.Infof("Executed CreateApiKey, apiKeyId: %s, prefix: %s", id, prefix)
+	return &models.CreatedApiKey{
+		ApiKey: &models.ApiKey{
+			Id:        objectId,
+			Name:      schema.Name,
+			Prefix:    schema.Prefix,
+			Scopes:    schema.Scopes,
+			ExpiresAt: schema.ExpiresAt,
+			CreatedBy: schema.CreatedBy,
+			CreatedAt: schema.CreatedAt,
+		},
+		Key: key,
+	}, nil
+}
+
+func (a *akservice) GetApiKeys(context context.Context) ([]*models.ApiKey, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing GetApiKeys...")
+	apiKeys, dberror := a.dbservice.GetApiKeys(context)
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	logger.Infof("Executed GetApiKeys, api keys: %d", len(apiKeys))
+	return apiKeys, nil
+}
+
+func (a *akservice) RevokeApiKey(context context.Context, apiKeyId string) error {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing RevokeApiKey, apiKeyId: %s", apiKeyId)
+	dberror := a.dbservice.RevokeApiKey(context, apiKeyId, time.Now().UTC())
+	if dberror != nil {
+		logger.Error(dberror)
+		return dberror
+	}
+	logger.Infof("Executed RevokeApiKey, apiKeyId: %s", apiKeyId)
+	return nil
+}
+
+// function to authenticate api key, unknown, revoked and expired keys are unauthorized
+func (a *akservice) Authenticate(context context.Context, key string) (*appauth.Principal, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	prefix, _, found := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), "_")
+	if !strings.HasPrefix(key, apiKeyPrefix) || !found || len(prefix) != 2*apiKeyPrefixBytes {
+		return nil, apperrors.NewUnauthorized("invalid api key")
+	}
+	apiKey, dberror := a.dbservice.GetApiKeyByPrefix(context, prefix)
+	if dberror != nil {
+		if apperrors.KindOf(dberror) == apperrors.NotFound {
+			return nil, apperrors.NewUnauthorized("invalid api key")
+		}
+		return nil, dberror
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(apiKey.Hash)) != 1 {
+		return nil, apperrors.NewUnauthorized("invalid api key")
+	}
+	now := time.Now().UTC()
+	if apiKey.RevokedAt != nil {
+		logger.Warnf("revoked api key used, prefix: %s", prefix)
+		return nil, apperrors.NewUnauthorized("api key is revoked")
+	}
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(now) {
+		logger.Warnf("expired api key used, prefix: %s", prefix)
+		return nil, apperrors.NewUnauthorized("api key is expired")
+	}
+
+	// failing to track usage must not fail the request
+	if uerror := a.dbservice.UpdateLastUsed(context, apiKey.Id, now, apiKeyUsedInterval); uerror != nil {
+		logger.Warnf("cannot update last used time of api key, prefix: %s, error: %v", prefix, uerror)
+	}
+	return &appauth.Principal{
+		Subject: apiKey.Id.Hex(),
+		Type:    appauth.PrincipalTypeApiKey,
+		Role:    appauth.RoleService,
+		Scopes:  apiKey.Scopes,
+	}, nil
+}
+
+// function to hash api keys and refresh tokens, tokens are random so unsalted sha256 is sufficient
+func hashToken(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(size int) (string, error) {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(value), nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApiKeyServiceAuthenticate(t *testing.T) {
+	ctx, _ := apploggers.NewLogger()
+	dbservice := &fakeApiKeyDbService{}
+	service := NewApiKeyService(dbservice)
+
+	created, err := service.CreateApiKey(ctx, &models.CreateApiKeyRequest{Name: "billing", Scopes: []string{"users:write", "users:read", "users:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Key, apiKeyPrefix+created.Prefix+"_") {
+		t.Fatalf("unexpected key format: %s", created.Key)
+	}
+	if stored := dbservice.keys[0]; stored.Hash != hashToken(created.Key) || strings.Contains(stored.Hash, created.Key) {
+		t.Fatalf("key must only be stored hashed: %s", stored.Hash)
+	}
+	if scopes := strings.Join(created.Scopes, " "); scopes != "users:read users:write" {
+		t.Fatalf("unexpected scopes: %s", scopes)
+	}
+
+	revoked, _ := service.CreateApiKey(ctx, &models.CreateApiKeyRequest{Name: "revoked", Scopes: []string{"users:read"}})
+	revokedAt := time.Now()
+	dbservice.keys[1].RevokedAt = &revokedAt
+	expired, _ := service.CreateApiKey(ctx, &models.CreateApiKeyRequest{Name: "expired", Scopes: []string{"users:read"}})
+	expiredAt := time.Now().Add(-time.Minute)
+	dbservice.keys[2].ExpiresAt = &expiredAt
+
+	secret := strings.TrimPrefix(created.Key, apiKeyPrefix+created.Prefix+"_")
+	tests := []struct {
+		name  string
+		key   string
+		valid bool
+	}{
+		{"valid key", created.Key, true},
+		{"empty key", "", false},
+		{"without prefix", strings.TrimPrefix(created.Key, apiKeyPrefix), false},
+		{"without secret", apiKeyPrefix + created.Prefix, false},
+		{"short prefix", apiKeyPrefix + created.Prefix[1:] + "_" + secret, false},
+		{"unknown prefix", apiKeyPrefix + strings.Repeat("0", len(created.Prefix)) + "_" + secret, false},
+		{"wrong secret", apiKeyPrefix + created.Prefix + "_" + strings.Repeat("0", len(secret)), false},
+		{"revoked key", revoked.Key, false},
+		{"expired key", expired.Key, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := service.Authenticate(ctx, test.key)
+			if !test.valid {
+				if apperrors.KindOf(err) != apperrors.Unauthorized {
+					t.Fatalf("expected unauthorized, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Type != appauth.PrincipalTypeApiKey || principal.Role != appauth.RoleService || principal.Subject != created.Id.Hex() {
+				t.Fatalf("unexpected principal: %+v", principal)
+			}
+			if !principal.HasPermission(appauth.PermissionWriteUsers) || principal.HasPermission(appauth.PermissionDeleteUsers) {
+				t.Fatalf("unexpected permissions of scopes: %v", principal.Scopes)
+			}
+		})
+	}
+}
+
+func TestCreateApiKeyRejectsPastExpiry(t *testing.T) {
+	ctx, _ := apploggers.NewLogger()
+	expiresAt := time.Now().Add(-time.Hour)
+	_, err := NewApiKeyService(&fakeApiKeyDbService{}).CreateApiKey(ctx, &models.CreateApiKeyRequest{Name: "old", Scopes: []string{"users:read"}, ExpiresAt: &expiresAt})
+	if apperrors.KindOf(err) != apperrors.InvalidArgument {
+		t.Fatalf("expected invalid argument, got: %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/db"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	refreshTokenPrefix = "rt_"
+	refreshTokenBytes  = 32
+	// authentication method of password logins, see RFC 8176
+	amrPassword = "pwd"
+)
+
+// options of logins and issued tokens
+type AuthOptions struct {
+	AccessTokenTtl   time.Duration
+	RefreshTokenTtl  time.Duration
+	PasswordPolicy   *appauth.PasswordPolicy
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// cipher of the totp secrets of second factors
+	TotpCipher *appauth.SecretCipher
+}
+
+type AuthService interface {
+	Login(context context.Context, request *models.LoginRequest) (*models.TokenResponse, error)
+	Refresh(context context.Context, refreshToken string) (*models.TokenResponse, error)
+	Logout(context context.Context, refreshToken string) error
+	SetPassword(context context.Context, userId string, request *models.PasswordRequest) error
+}
+
+type aservice struct {
+	dbservice   db.DbService
+	cdbservice  db.CredentialDbService
+	rtdbservice db.RefreshTokenDbService
+	signer      *appauth.JwtSigner
+	options     *AuthOptions
+}
+
+// hash verified for unknown users, so that login takes the same time for known and unknown emails
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := appauth.HashPassword("dummy password of unknown users")
+	return hash
+})
+
+func NewAuthService(dbservice db.DbService, cdbservice db.CredentialDbService, rtdbservice db.RefreshTokenDbService, signer *appauth.JwtSigner, options *AuthOptions) AuthService {
+	return &aservice{
+		dbservice:   dbservice,
+		cdbservice:  cdbservice,
+		rtdbservice: rtdbservice,
+		signer:      signer,
+		options:     options,
+	}
+}
+
+// function to login with email and password, all failures return the same error to not reveal accounts
+// failed attempts are counted and the account is locked when they reach the lockout threshold
+func (a *aservice) Login(context context.Context, request *models.LoginRequest) (*models.TokenResponse, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing Login")
+	user, dberror := a.dbservice.GetUserByEmail(context, models.NormalizeEmail(request.Email), []string{"_id", "is_active"})
+	if apperrors.KindOf(dberror) == apperrors.NotFound {
+		appauth.VerifyPassword(request.Password, dummyPasswordHash())
+		logger.Warn("login failed, unknown email")
+		return nil, invalidCredentials()
+	}
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	credential, dberror := a.cdbservice.GetCredential(context, user.Id)
+	if apperrors.KindOf(dberror) == apperrors.NotFound {
+		appauth.VerifyPassword(request.Password, dummyPasswordHash())
+		logger.Warnf("login failed, user has no password, userId: %s", user.Id.Hex())
+		return nil, invalidCredentials()
+	}
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+
+	now := time.Now().UTC()
+	if credential.LockedUntil != nil && credential.LockedUntil.After(now) {
+		appauth.VerifyPassword(request.Password, dummyPasswordHash())
+		logger.Warnf("login failed, account is locked, userId: %s, lockedUntil: %s", user.Id.Hex(), credential.LockedUntil.Format(time.RFC3339))
+		return nil, invalidCredentials()
+	}
+	matched, verror := appauth.VerifyPassword(request.Password, credential.Hash)
+	if verror != nil {
+		logger.Error(verror)
+		return nil, apperrors.NewInternal("cannot verify password", verror)
+	}
+	if !matched {
+		logger.Warnf("login failed, wrong password, userId: %s, failed attempts: %d", user.Id.Hex(), credential.FailedAttempts+1)
+		if rerror := a.cdbservice.RecordFailedLogin(context, user.Id, a.options.LockoutThreshold, now.Add(a.options.LockoutDuration)); rerror != nil {
+			logger.Error(rerror)
+		}
+		return nil, invalidCredentials()
+	}
+	if !user.IsActive {
+		logger.Warnf("login failed, user is not active, userId: %s", user.Id.Hex())
+		return nil, invalidCredentials()
+	}
+
+	amr := []string{amrPassword}
+	if credential.TotpEnabled {
+		if len(strings.TrimSpace(request.Otp)) == 0 {
+			logger.Infof("login requires one-time password, userId: %s", user.Id.Hex())
+			return nil, apperrors.NewUnauthorized("one-time password is required").WithDetails(map[string]interface{}{
+				"mfa_required": true,
+			})
+		}
+		verified, verror := verifySecondFactor(context, a.cdbservice, a.options.TotpCipher, credential, request.Otp)
+		if verror != nil {
+			logger.Error(verror)
+			return nil, verror
+		}
+		if !verified {
+			logger.Warnf("login failed, wrong one-time password, userId: %s", user.Id.Hex())
+			if rerror := a.cdbservice.RecordFailedLogin(context, user.Id, a.options.LockoutThreshold, now.Add(a.options.LockoutDuration)); rerror != nil {
+				logger.Error(rerror)
+			}
+			return nil, invalidCredentials()
+		}
+		amr = append(amr, amrOtp)
+	}
+
+	if credential.FailedAttempts > 0 || credential.LockedUntil != nil {
+		if rerror := a.cdbservice.ResetFailedLogins(context, user.Id); rerror != nil {
+			logger.Warnf("cannot reset failed logins, userId: %s, error: %v", user.Id.Hex(), rerror)
+		}
+	}
+	// hashes with outdated parameters or bcrypt hashes are upgraded on login
+	if appauth.NeedsRehash(credential.Hash) {
+		if hash, herror := appauth.HashPassword(request.Password); herror == nil {
+			if serror := a.cdbservice.SavePassword(context, user.Id, hash, now); serror != nil {
+				logger.Warnf("cannot upgrade password hash, userId: %s, error: %v", user.Id.Hex(), serror)
+			}
+		}
+	}
+	tokens, terror := a.issueTokens(context, user.Id, amr)
+	if terror != nil {
+		logger.Error(terror)
+		return nil, terror
+	}
+	logger.Infof("Executed Login, userId: %s", user.Id.Hex())
+	return tokens, nil
+}
+
+// function to exchange refresh token for new tokens, refresh tokens are rotated and can be used once
+// reuse of a rotated token revokes all refresh tokens of the user, as the token may be stolen
+func (a *aservice) Refresh(context context.Context, refreshToken string) (*models.TokenResponse, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing Refresh")
+	token, dberror := a.rtdbservice.GetRefreshToken(context, hashToken(refreshToken))
+	if apperrors.KindOf(dberror) == apperrors.NotFound {
+		return nil, apperrors.NewUnauthorized("invalid refresh token")
+	}
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	now := time.Now().UTC()
+	if token.RevokedAt != nil {
+		logger.Warnf("revoked refresh token reused, revoking all refresh tokens, userId: %s", token.UserId.Hex())
+		a.revokeUserTokens(context, token.UserId, now)
+		return nil, apperrors.NewUnauthorized("invalid refresh token")
+	}
+	if !token.ExpiresAt.After(now) {
+		return nil, apperrors.NewUnauthorized("refresh token is expired")
+	}
+	revoked, rerror := a.rtdbservice.RevokeRefreshToken(context, token.Id, now)
+	if rerror != nil {
+		logger.Error(rerror)
+		return nil, rerror
+	}
+	if !revoked {
+		logger.Warnf("refresh token used concurrently, revoking all refresh tokens, userId: %s", token.UserId.Hex())
+		a.revokeUserTokens(context, token.UserId, now)
+		return nil, apperrors.NewUnauthorized("invalid refresh token")
+	}
+
+	user, dberror := a.dbservice.GetUserById(context, token.UserId.Hex(), []string{"is_active"})
+	if apperrors.KindOf(dberror) == apperrors.NotFound || (dberror == nil && !user.IsActive) {
+		logger.Warnf("refresh failed, user is deleted or not active, userId: %s", token.UserId.Hex())
+		return nil, apperrors.NewUnauthorized("invalid refresh token")
+	}
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	tokens, terror := a.issueTokens(context, token.UserId, token.Amr)
+	if terror != nil {
+		logger.Error(terror)
+		return nil, terror
+	}
+	logger.Infof("Executed Refresh, userId: %s", token.UserId.Hex())
+	return tokens, nil
+}
+
+// function to revoke refresh token, unknown tokens are ignored
+func (a *aservice) Logout(context context.Context, refreshToken string) error {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing Logout")
+	token, dberror := a.rtdbservice.GetRefreshToken(context, hashToken(refreshToken))
+	if apperrors.KindOf(dberror) == apperrors.NotFound {
+		logger.Infof("Executed Logout, unknown refresh token")
+		return nil
+	}
+	if dberror != nil {
+		logger.Error(dberror)
+		return dberror
+	}
+	if _, rerror := a.rtdbservice.RevokeRefreshToken(context, token.Id, time.Now().UTC()); rerror != nil {
+		logger.Error(rerror)
+		return rerror
+	}
+	logger.Infof("Executed Logout, userId: %s", token.UserId.Hex())
+	return nil
+}
+
+// function to set password of user, users changing their own password must provide the current password
+// refresh tokens of the user are revoked, so that other sessions must login with the new password
+func (a *aservice) SetPassword(context context.Context, userId string, request *models.PasswordRequest) error {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing SetPassword, userId: %s", userId)
+	if aerror := appauth.Authorize(context, appauth.PermissionSetPassword, userId); aerror != nil {
+		return aerror
+	}
+	user, dberror := a.dbservice.GetUserById(context, userId, []string{"_id", "email"})
+	if dberror != nil {
+		logger.Error(dberror)
+		return dberror
+	}
+	if verror := a.options.PasswordPolicy.Validate(request.Password, user.Email); verror != nil {
+		return verror
+	}
+
+	now := time.Now().UTC()
+	// the current password is only skipped for other principals, e.g. admins resetting the password of a user
+	principal := appauth.GetPrincipal(context)
+	if principal == nil || (principal.Type == appauth.PrincipalTypeUser && principal.Subject == userId) {
+		credential, cerror := a.cdbservice.GetCredential(context, user.Id)
+		if cerror != nil && apperrors.KindOf(cerror) != apperrors.NotFound {
+			logger.Error(cerror)
+			return cerror
+		}
+		if credential != nil {
+			matched, verror := appauth.VerifyPassword(request.CurrentPassword, credential.Hash)
+			if verror != nil {
+				logger.Error(verror)
+				return apperrors.NewInternal("cannot verify password", verror)
+			}
+			if !matched {
+				logger.Warnf("password change failed, wrong current password, userId: %s", userId)
+				if rerror := a.cdbservice.RecordFailedLogin(context, user.Id, a.options.LockoutThreshold, now.Add(a.options.LockoutDuration)); rerror != nil {
+					logger.Error(rerror)
+				}
+				return apperrors.NewInvalidArgument("invalid request payload").WithDetails(map[string]interface{}{
+					"current_password": "is incorrect",
+				})
+			}
+		}
+	}
+
+	hash, herror := appauth.HashPassword(request.Password)
+	if herror != nil {
+		logger.Error(herror)
+		return apperrors.NewInternal("cannot hash password", herror)
+	}
+	if dberror := a.cdbservice.SavePassword(context, user.Id, hash, now); dberror != nil {
+		logger.Error(dberror)
+		return dberror
+	}
+	a.revokeUserTokens(context, user.Id, now)
+	logger.Infof("Executed SetPassword, userId: %s", userId)
+	return nil
+}
+
+// function to issue access token and refresh token of the user
+func (a *aservice) issueTokens(context context.Context, userId primitive.ObjectID, amr []string) (*models.TokenResponse, error) {
+	accessToken, serror := a.signer.Sign(userId.Hex(), amr, a.options.AccessTokenTtl)
+	if serror != nil {
+		return nil, apperrors.NewInternal("cannot sign access token", serror)
+	}
+	secret, rerror := randomHex(refreshTokenBytes)
+	if rerror != nil {
+		return nil, apperrors.NewInternal("cannot generate refresh token", rerror)
+	}
+	refreshToken := refreshTokenPrefix + secret
+	now := time.Now().UTC()
+	dberror := a.rtdbservice.SaveRefreshToken(context, &dbmodel.RefreshTokenSchema{
+		UserId:    userId,
+		Hash:      hashToken(refreshToken),
+		Amr:       amr,
+		ExpiresAt: now.Add(a.options.RefreshTokenTtl),
+		CreatedAt: now,
+	})
+	if dberror != nil {
+		return nil, dberror
+	}
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(a.options.AccessTokenTtl.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (a *aservice) revokeUserTokens(context context.Context, userId primitive.ObjectID, now time.Time) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	if _, rerror := a.rtdbservice.RevokeUserRefreshTokens(context, userId, now); rerror != nil {
+		logger.Errorf("cannot revoke refresh tokens, userId: %s, error: %v", userId.Hex(), rerror)
+	}
+}
+
+func invalidCredentials() error {
+	return apperrors.NewUnauthorized("invalid email or password")
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testPassword = "correct horse battery staple"
+
+// services and fakes of auth tests, with an active user which has the test password
+type authTest struct {
+	ctx     context.Context
+	service AuthService
+	users   *fakeUserDbService
+	creds   *fakeCredentialDbService
+	tokens  *fakeRefreshTokenDbService
+	cipher  *appauth.SecretCipher
+	user    *models.User
+}
+
+func newAuthTest(t *testing.T, lockoutThreshold int) *authTest {
+	t.Helper()
+	ctx, _ := apploggers.NewLogger()
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("0123456789abcdef0123456789abcdef"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := appauth.NewJwtSigner(&appauth.JwtConfig{Issuer: "issuer", Audience: "audience", HmacSecretFile: secretFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := appauth.LoadSecretCipher(secretFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := appauth.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Id: primitive.NewObjectID(), UserProperties: models.UserProperties{Name: "Ada", Email: "ada@example.com", IsActive: true}}
+	test := &authTest{
+		ctx:   ctx,
+		users: &fakeUserDbService{users: []*models.User{user}},
+		creds: &fakeCredentialDbService{credentials: map[primitive.ObjectID]*dbmodel.CredentialSchema{
+			user.Id: {UserId: user.Id, Hash: hash},
+		}},
+		tokens: &fakeRefreshTokenDbService{},
+		cipher: cipher,
+		user:   user,
+	}
+	test.service = NewAuthService(test.users, test.creds, test.tokens, signer, &AuthOptions{
+		AccessTokenTtl:   time.Minute,
+		RefreshTokenTtl:  time.Hour,
+		PasswordPolicy:   &appauth.PasswordPolicy{MinLength: 12, MinClasses: 2},
+		LockoutThreshold: lockoutThreshold,
+		LockoutDuration:  time.Hour,
+		TotpCipher:       cipher,
+	})
+	return test
+}
+
+func (a *authTest) login(password string) (*models.TokenResponse, error) {
+	return a.loginWithOtp(password, "")
+}
+
+func (a *authTest) loginWithOtp(password string, otp string) (*models.TokenResponse, error) {
+	return a.service.Login(a.ctx, &models.LoginRequest{Email: "ADA@example.com ", Password: password, Otp: otp})
+}
+
+func expectUnauthorized(t *testing.T, err error) {
+	t.Helper()
+	if apperrors.KindOf(err) != apperrors.Unauthorized {
+		t.Fatalf("expected unauthorized, got: %v", err)
+	}
+}
+
+func TestLoginLocksAccountAtThreshold(t *testing.T) {
+	test := newAuthTest(t, 2)
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := test.login("wrong password")
+		expectUnauthorized(t, err)
+	}
+	if test.creds.credentials[test.user.Id].LockedUntil == nil {
+		t.Fatal("account must be locked at the threshold")
+	}
+	_, err := test.login(testPassword)
+	expectUnauthorized(t, err)
+
+	past := time.Now().Add(-time.Minute)
+	test.creds.credentials[test.user.Id].LockedUntil = &past
+	if _, err := test.login(testPassword); err != nil {
+		t.Fatalf("login after the lockout must succeed: %v", err)
+	}
+	if credential := test.creds.credentials[test.user.Id]; credential.LockedUntil != nil || credential.FailedAttempts != 0 {
+		t.Fatalf("failed logins must be reset: %+v", credential)
+	}
+}
+
+func TestLoginWithoutLockout(t *testing.T) {
+	test := newAuthTest(t, 0)
+	for attempt := 0; attempt < 3; attempt++ {
+		_, err := test.login("wrong password")
+		expectUnauthorized(t, err)
+	}
+	if _, err := test.login(testPassword); err != nil {
+		t.Fatalf("threshold 0 must not lock the account: %v", err)
+	}
+}
+
+func TestLoginFailuresDoNotRevealAccounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(test *authTest)
+		email   string
+	}{
+		{"unknown email", func(test *authTest) {}, "unknown@example.com"},
+		{"user without password", func(test *authTest) { delete(test.creds.credentials, test.user.Id) }, "ada@example.com"},
+		{"inactive user", func(test *authTest) { test.user.IsActive = false }, "ada@example.com"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auth := newAuthTest(t, 5)
+			test.prepare(auth)
+			_, err := auth.service.Login(auth.ctx, &models.LoginRequest{Email: test.email, Password: testPassword})
+			expectUnauthorized(t, err)
+			if err.Error() != invalidCredentials().Error() {
+				t.Fatalf("unexpected error message: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshRotatesTokensAndDetectsReuse(t *testing.T) {
+	test := newAuthTest(t, 5)
+	first, err := test.login(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := test.service.Refresh(test.ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || len(second.AccessToken) == 0 {
+		t.Fatal("refresh must issue new tokens")
+	}
+	if active := test.tokens.activeTokens(test.user.Id); active != 1 {
+		t.Fatalf("only the rotated token must be active, got %d", active)
+	}
+
+	// reuse of the rotated token revokes the tokens of the user, including the token of the second refresh
+	_, err = test.service.Refresh(test.ctx, first.RefreshToken)
+	expectUnauthorized(t, err)
+	if active := test.tokens.activeTokens(test.user.Id); active != 0 {
+		t.Fatalf("reuse must revoke all tokens, got %d active", active)
+	}
+	_, err = test.service.Refresh(test.ctx, second.RefreshToken)
+	expectUnauthorized(t, err)
+}
+
+func TestRefreshRejectsInvalidTokens(t *testing.T) {
+	test := newAuthTest(t, 5)
+	tokens, err := test.login(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = test.service.Refresh(test.ctx, "rt_unknown")
+	expectUnauthorized(t, err)
+
+	test.tokens.tokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+	_, err = test.service.Refresh(test.ctx, tokens.RefreshToken)
+	expectUnauthorized(t, err)
+
+	test.tokens.tokens[0].ExpiresAt = time.Now().Add(time.Hour)
+	test.user.IsActive = false
+	_, err = test.service.Refresh(test.ctx, tokens.RefreshToken)
+	expectUnauthorized(t, err)
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	test := newAuthTest(t, 5)
+	tokens, err := test.login(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := test.service.Logout(test.ctx, tokens.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if err := test.service.Logout(test.ctx, "rt_unknown"); err != nil {
+		t.Fatalf("unknown tokens must be ignored: %v", err)
+	}
+	_, err = test.service.Refresh(test.ctx, tokens.RefreshToken)
+	expectUnauthorized(t, err)
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"context"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultLogLimit = 500
+	maxLogLimit     = 5000
+)
+
+type DebugLogService interface {
+	GetRecentLogs(context context.Context, query *models.LogQuery) ([]*models.LogEntry, error)
+}
+
+type dlservice struct{}
+
+func NewDebugLogService() DebugLogService {
+	return &dlservice{}
+}
+
+// function to get recent log entries of the instance, e.g. the trail of a failed request by its correlation id
+func (d *dlservice) GetRecentLogs(context context.Context, query *models.LogQuery) ([]*models.LogEntry, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing GetRecentLogs, correlationId: %s", query.CorrelationId)
+	if !apploggers.RecentLogsEnabled() {
+		return nil, apperrors.NewNotFound("recent logs are disabled")
+	}
+	recentQuery := &apploggers.LogQuery{CorrelationId: query.CorrelationId, MinLevel: zapcore.DebugLevel, Limit: defaultLogLimit}
+	if len(query.Level) > 0 {
+		level, lerror := zapcore.ParseLevel(query.Level)
+		if lerror != nil {
+			return nil, apperrors.NewInvalidArgument("'level' must be debug, info, warn or error: " + query.Level)
+		}
+		recentQuery.MinLevel = level
+	}
+	if query.Since != nil {
+		recentQuery.Since = *query.Since
+	}
+	if query.Limit != 0 {
+		if query.Limit < 0 || query.Limit > maxLogLimit {
+			return nil, apperrors.NewInvalidArgument("'limit' must be between 1 and 5000")
+		}
+		recentQuery.Limit = query.Limit
+	}
+
+	entries := []*models.LogEntry{}
+	for _, entry := range apploggers.FindRecentLogs(recentQuery) {
+		entries = append(entries, &models.LogEntry{
+			Time:          entry.Time,
+			Level:         entry.Level.String(),
+			Caller:        entry.Caller,
+			Message:       entry.Message,
+			CorrelationId: entry.CorrelationId,
+			Fields:        entry.Fields,
+		})
+	}
+	logger.Infof("Executed GetRecentLogs, entries: %d", len(entries))
+	return entries, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/db"
+	"GolangCourse/internals/models"
+	"context"
+)
+
+type EmailMigrationService interface {
+	NormalizeEmails(context context.Context, dryRun bool) (*models.EmailMigrationSummary, error)
+}
+
+type emservice struct {
+	dbservice db.DbService
+}
+
+func NewEmailMigrationService(dbservice db.DbService) EmailMigrationService {
+	return &emservice{
+		dbservice: dbservice,
+	}
+}
+
+// function to normalise the emails of users stored before emails were normalised, so that the unique index can be created
+// users sharing a normalised email are reported and not changed, they must be resolved before the index is created
+func (m *emservice) NormalizeEmails(context context.Context, dryRun bool) (*models.EmailMigrationSummary, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing NormalizeEmails, dryRun: %t", dryRun)
+	if aerror := appauth.Authorize(context, appauth.PermissionWriteUsers, ""); aerror != nil {
+		return nil, aerror
+	}
+	summary := &models.EmailMigrationSummary{Duplicates: map[string][]string{}}
+	emails := map[string]string{}
+	users := map[string][]string{}
+	dberror := m.dbservice.StreamUsers(context, nil, []string{"_id", "email"}, func(user *models.User) error {
+		summary.Total++
+		emails[user.Id.Hex()] = user.Email
+		normalized := models.NormalizeEmail(user.Email)
+		users[normalized] = append(users[normalized], user.Id.Hex())
+		return nil
+	})
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+
+	for normalized, userIds := range users {
+		if len(userIds) > 1 {
+			summary.Duplicates[normalized] = userIds
+			continue
+		}
+		if emails[userIds[0]] == normalized {
+			continue
+		}
+		summary.Normalized++
+		if dryRun {
+			continue
+		}
+		if uerror := m.dbservice.UpdateUserFields(context, userIds[0], map[string]interface{}{"email": normalized}); uerror != nil {
+			logger.Error(uerror)
+			return nil, uerror
+		}
+	}
+	logger.Infof("Executed NormalizeEmails, users: %d, normalized: %d, duplicates: %d", summary.Total, summary.Normalized, len(summary.Duplicates))
+	return summary, nil
+}
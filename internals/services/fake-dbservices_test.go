@@ -0,0 +1,259 @@
+package services
+
+import (
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/internals/db"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// in-memory api key db service of tests
+type fakeApiKeyDbService struct {
+	db.ApiKeyDbService
+	keys []*models.ApiKey
+}
+
+func (f *fakeApiKeyDbService) SaveApiKey(ctx context.Context, apiKey *dbmodel.ApiKeySchema) (string, error) {
+	id := primitive.NewObjectID()
+	f.keys = append(f.keys, &models.ApiKey{
+		Id:        id,
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Hash:      apiKey.Hash,
+		Scopes:    apiKey.Scopes,
+		ExpiresAt: apiKey.ExpiresAt,
+		CreatedBy: apiKey.CreatedBy,
+		CreatedAt: apiKey.CreatedAt,
+	})
+	return id.Hex(), nil
+}
+
+func (f *fakeApiKeyDbService) GetApiKeyByPrefix(ctx context.Context, prefix string) (*models.ApiKey, error) {
+	for _, key := range f.keys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+	return nil, apperrors.NewNotFound("cannot get api key")
+}
+
+func (f *fakeApiKeyDbService) UpdateLastUsed(ctx context.Context, apiKeyId primitive.ObjectID, usedAt time.Time, interval time.Duration) error {
+	for _, key := range f.keys {
+		if key.Id == apiKeyId {
+			key.LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+// in-memory user db service of tests, emails are looked up case insensitively like the email index
+type fakeUserDbService struct {
+	db.DbService
+	users   []*models.User
+	upserts [][]*dbmodel.UserUpsert
+}
+
+func (f *fakeUserDbService) GetUserById(ctx context.Context, userId string, fields []string) (*models.User, error) {
+	for _, user := range f.users {
+		if user.Id.Hex() == userId {
+			return user, nil
+		}
+	}
+	return nil, apperrors.NewNotFound("cannot get user")
+}
+
+func (f *fakeUserDbService) GetUserByEmail(ctx context.Context, email string, fields []string) (*models.User, error) {
+	for _, user := range f.users {
+		if models.NormalizeEmail(user.Email) == models.NormalizeEmail(email) {
+			return user, nil
+		}
+	}
+	return nil, apperrors.NewNotFound("cannot get user by email")
+}
+
+func (f *fakeUserDbService) GetUsersByEmails(ctx context.Context, emails []string, fields []string) ([]*models.User, error) {
+	var users []*models.User
+	for _, email := range emails {
+		if user, err := f.GetUserByEmail(ctx, email, fields); err == nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// function to upsert users like the bulk write, provided fields are set on existing users
+func (f *fakeUserDbService) UpsertUsersByEmail(ctx context.Context, users []*dbmodel.UserUpsert) (*dbmodel.BulkUpsertResult, error) {
+	f.upserts = append(f.upserts, users)
+	result := &dbmodel.BulkUpsertResult{Failures: map[int]string{}}
+	for _, upsert := range users {
+		existing, _ := f.GetUserByEmail(ctx, upsert.User.Email, nil)
+		if existing == nil {
+			if !upsert.UpdateOnly {
+				f.users = append(f.users, &models.User{Id: primitive.NewObjectID(), UserProperties: models.UserProperties{
+					Name: upsert.User.Name, Email: upsert.User.Email, Type: upsert.User.Type, Age: upsert.User.Age, IsActive: upsert.User.IsActive,
+				}})
+				result.Inserted++
+			}
+			continue
+		}
+		for _, field := range upsert.Fields {
+			switch field {
+			case "name":
+				existing.Name = upsert.User.Name
+			case "type":
+				existing.Type = upsert.User.Type
+			case "age":
+				existing.Age = upsert.User.Age
+			case "is_active":
+				existing.IsActive = upsert.User.IsActive
+			}
+		}
+		result.Updated++
+	}
+	return result, nil
+}
+
+// in-memory credential db service of tests, failed logins are counted and locked like the update of the db service
+type fakeCredentialDbService struct {
+	db.CredentialDbService
+	credentials map[primitive.ObjectID]*dbmodel.CredentialSchema
+}
+
+func (f *fakeCredentialDbService) GetCredential(ctx context.Context, userId primitive.ObjectID) (*dbmodel.CredentialSchema, error) {
+	credential, ok := f.credentials[userId]
+	if !ok {
+		return nil, apperrors.NewNotFound("cannot get credential")
+	}
+	copied := *credential
+	return &copied, nil
+}
+
+func (f *fakeCredentialDbService) SavePassword(ctx context.Context, userId primitive.ObjectID, hash string, updatedAt time.Time) error {
+	credential, ok := f.credentials[userId]
+	if !ok {
+		credential = &dbmodel.CredentialSchema{UserId: userId}
+		f.credentials[userId] = credential
+	}
+	credential.Hash, credential.FailedAttempts, credential.LockedUntil, credential.UpdatedAt = hash, 0, nil, updatedAt
+	return nil
+}
+
+func (f *fakeCredentialDbService) RecordFailedLogin(ctx context.Context, userId primitive.ObjectID, threshold int, lockedUntil time.Time) error {
+	credential := f.credentials[userId]
+	if threshold <= 0 || credential == nil {
+		return nil
+	}
+	credential.FailedAttempts++
+	if credential.FailedAttempts >= threshold {
+		credential.LockedUntil, credential.FailedAttempts = &lockedUntil, 0
+	}
+	return nil
+}
+
+func (f *fakeCredentialDbService) ResetFailedLogins(ctx context.Context, userId primitive.ObjectID) error {
+	if credential := f.credentials[userId]; credential != nil {
+		credential.FailedAttempts, credential.LockedUntil = 0, nil
+	}
+	return nil
+}
+
+func (f *fakeCredentialDbService) SavePendingTotp(ctx context.Context, userId primitive.ObjectID, secret string) error {
+	credential := f.credentials[userId]
+	if credential == nil {
+		return apperrors.NewNotFound("cannot save totp secret, credential not found")
+	}
+	credential.TotpPendingSecret = secret
+	return nil
+}
+
+func (f *fakeCredentialDbService) EnableTotp(ctx context.Context, userId primitive.ObjectID, secret string, step int64, recoveryCodes []string) error {
+	credential := f.credentials[userId]
+	if credential == nil || credential.TotpPendingSecret != secret {
+		return apperrors.NewConflict("cannot enable totp, enrollment was restarted")
+	}
+	credential.TotpEnabled, credential.TotpSecret, credential.TotpLastStep = true, secret, step
+	credential.RecoveryCodes, credential.TotpPendingSecret = recoveryCodes, ""
+	return nil
+}
+
+func (f *fakeCredentialDbService) UseTotpStep(ctx context.Context, userId primitive.ObjectID, step int64) (bool, error) {
+	credential := f.credentials[userId]
+	if credential == nil || credential.TotpLastStep >= step {
+		return false, nil
+	}
+	credential.TotpLastStep = step
+	return true, nil
+}
+
+func (f *fakeCredentialDbService) UseRecoveryCode(ctx context.Context, userId primitive.ObjectID, hash string) (bool, error) {
+	credential := f.credentials[userId]
+	if credential == nil {
+		return false, nil
+	}
+	for index, code := range credential.RecoveryCodes {
+		if code == hash {
+			credential.RecoveryCodes = append(credential.RecoveryCodes[:index:index], credential.RecoveryCodes[index+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// in-memory refresh token db service of tests
+type fakeRefreshTokenDbService struct {
+	db.RefreshTokenDbService
+	tokens []*dbmodel.RefreshTokenSchema
+}
+
+func (f *fakeRefreshTokenDbService) GetRefreshToken(ctx context.Context, hash string) (*dbmodel.RefreshTokenSchema, error) {
+	for _, token := range f.tokens {
+		if token.Hash == hash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.NewNotFound("cannot get refresh token")
+}
+
+func (f *fakeRefreshTokenDbService) SaveRefreshToken(ctx context.Context, token *dbmodel.RefreshTokenSchema) error {
+	saved := *token
+	saved.Id = primitive.NewObjectID()
+	f.tokens = append(f.tokens, &saved)
+	return nil
+}
+
+func (f *fakeRefreshTokenDbService) RevokeRefreshToken(ctx context.Context, tokenId primitive.ObjectID, revokedAt time.Time) (bool, error) {
+	for _, token := range f.tokens {
+		if token.Id == tokenId && token.RevokedAt == nil {
+			token.RevokedAt = &revokedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeRefreshTokenDbService) RevokeUserRefreshTokens(ctx context.Context, userId primitive.ObjectID, revokedAt time.Time) (int64, error) {
+	var revoked int64
+	for _, token := range f.tokens {
+		if token.UserId == userId && token.RevokedAt == nil {
+			token.RevokedAt = &revokedAt
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+// function to count the refresh tokens of the user which are not revoked
+func (f *fakeRefreshTokenDbService) activeTokens(userId primitive.ObjectID) int {
+	active := 0
+	for _, token := range f.tokens {
+		if token.UserId == userId && token.RevokedAt == nil {
+			active++
+		}
+	}
+	return active
+}
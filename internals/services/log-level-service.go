@@ -0,0 +1,69 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"context"
+	"time"
+)
+
+// max duration of temporary levels, so that forgotten debug levels are reverted
+const maxLogLevelTtl = 24 * time.Hour
+
+type LogLevelService interface {
+	GetLogLevels(context context.Context) *models.LogLevels
+	SetLogLevels(context context.Context, request *models.SetLogLevelsRequest) (*models.LogLevels, error)
+}
+
+type llservice struct{}
+
+func NewLogLevelService() LogLevelService {
+	return &llservice{}
+}
+
+// function to get the current levels of all loggers
+func (l *llservice) GetLogLevels(context context.Context) *models.LogLevels {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Info("Executing GetLogLevels")
+	levels := toLogLevels(apploggers.GetLevels())
+	logger.Info("Executed GetLogLevels")
+	return levels
+}
+
+// function to change the levels of all loggers, levels with ttl are reverted after the ttl
+func (l *llservice) SetLogLevels(context context.Context, request *models.SetLogLevelsRequest) (*models.LogLevels, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Info("Executing SetLogLevels")
+	var ttl time.Duration
+	if len(request.Ttl) > 0 {
+		parsed, perror := time.ParseDuration(request.Ttl)
+		if perror != nil || parsed <= 0 || parsed > maxLogLevelTtl {
+			return nil, apperrors.NewInvalidArgument("invalid request payload").WithDetails(map[string]interface{}{
+				"ttl": "must be a positive duration of at most 24h, e.g. 15m",
+			})
+		}
+		ttl = parsed
+	}
+	levels, serror := apploggers.SetLevels(request.Level, request.Packages, ttl)
+	if serror != nil {
+		return nil, apperrors.NewInvalidArgument(serror.Error())
+	}
+	// changes are logged as warning, so that they are logged with any level
+	changedBy := "anonymous"
+	if principal := appauth.GetPrincipal(context); principal != nil {
+		changedBy = principal.String()
+	}
+	logger.Warnf("log levels changed by %s, level: %s, packages: %v, ttl: %s", changedBy, levels.Level, levels.Packages, ttl)
+	logger.Info("Executed SetLogLevels")
+	return toLogLevels(levels), nil
+}
+
+func toLogLevels(levels *apploggers.Levels) *models.LogLevels {
+	return &models.LogLevels{
+		Level:    levels.Level,
+		Packages: levels.Packages,
+		RevertAt: levels.RevertAt,
+	}
+}
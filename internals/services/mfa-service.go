@@ -0,0 +1,197 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/db"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 10
+	// authentication method of one-time password logins, see RFC 8176
+	amrOtp = "otp"
+)
+
+type MfaService interface {
+	EnrollTotp(context context.Context, userId string) (*models.TotpEnrollment, error)
+	VerifyTotp(context context.Context, userId string, code string) (*models.RecoveryCodes, error)
+	ResetMfa(context context.Context, userId string) error
+}
+
+type mservice struct {
+	dbservice   db.DbService
+	cdbservice  db.CredentialDbService
+	rtdbservice db.RefreshTokenDbService
+	issuer      string
+	cipher      *appauth.SecretCipher
+}
+
+// function to create mfa service, totp secrets are stored encrypted with the cipher
+func NewMfaService(dbservice db.DbService, cdbservice db.CredentialDbService, rtdbservice db.RefreshTokenDbService, issuer string, cipher *appauth.SecretCipher) MfaService {
+	return &mservice{
+		dbservice:   dbservice,
+		cdbservice:  cdbservice,
+		rtdbservice: rtdbservice,
+		issuer:      issuer,
+		cipher:      cipher,
+	}
+}
+
+// function to start totp enrollment of user, totp is enabled when a code of the secret is verified
+// users must have a password, enrolling again replaces the pending secret
+func (m *mservice) EnrollTotp(context context.Context, userId string) (*models.TotpEnrollment, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing EnrollTotp, userId: %s", userId)
+	if aerror := appauth.Authorize(context, appauth.PermissionEnrollMfa, userId); aerror != nil {
+		return nil, aerror
+	}
+	user, credential, gerror := m.getCredential(context, userId)
+	if gerror != nil {
+		logger.Error(gerror)
+		return nil, gerror
+	}
+	if credential.TotpEnabled {
+		return nil, apperrors.NewConflict("two-factor authentication is already enabled")
+	}
+	secret, serror := appauth.GenerateTotpSecret()
+	if serror != nil {
+		return nil, apperrors.NewInternal("cannot generate totp secret", serror)
+	}
+	encrypted, eerror := m.cipher.Encrypt(secret, user.Id.Hex())
+	if eerror != nil {
+		return nil, apperrors.NewInternal("cannot encrypt totp secret", eerror)
+	}
+	if dberror := m.cdbservice.SavePendingTotp(context, user.Id, encrypted); dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	logger.Infof("Executed EnrollTotp, userId: %s", userId)
+	return &models.TotpEnrollment{
+		Secret: secret,
+		Uri:    appauth.TotpUri(m.issuer, user.Email, secret),
+	}, nil
+}
+
+// function to verify code of the pending secret and enable totp, recovery codes are generated
+func (m *mservice) VerifyTotp(context context.Context, userId string, code string) (*models.RecoveryCodes, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing VerifyTotp, userId: %s", userId)
+	if aerror := appauth.Authorize(context, appauth.PermissionEnrollMfa, userId); aerror != nil {
+		return nil, aerror
+	}
+	user, credential, gerror := m.getCredential(context, userId)
+	if gerror != nil {
+		logger.Error(gerror)
+		return nil, gerror
+	}
+	if len(credential.TotpPendingSecret) == 0 {
+		return nil, apperrors.NewConflict("two-factor authentication enrollment is not started")
+	}
+	secret, derror := m.cipher.Decrypt(credential.TotpPendingSecret, user.Id.Hex())
+	if derror != nil {
+		logger.Error(derror)
+		return nil, apperrors.NewInternal("cannot decrypt totp secret", derror)
+	}
+	step, valid := appauth.ValidateTotp(secret, code, time.Now())
+	if !valid {
+		logger.Warnf("totp verification failed, userId: %s", userId)
+		return nil, apperrors.NewInvalidArgument("invalid request payload").WithDetails(map[string]interface{}{
+			"code": "is incorrect",
+		})
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for index := range codes {
+		value, rerror := randomHex(recoveryCodeBytes)
+		if rerror != nil {
+			return nil, apperrors.NewInternal("cannot generate recovery codes", rerror)
+		}
+		codes[index] = strings.Join([]string{value[:5], value[5:10], value[10:15], value[15:]}, "-")
+		hashes[index] = m.cipher.Hash(codes[index], user.Id.Hex())
+	}
+	if dberror := m.cdbservice.EnableTotp(context, user.Id, credential.TotpPendingSecret, step, hashes); dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	logger.Infof("Executed VerifyTotp, userId: %s", userId)
+	return &models.RecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// function to reset two-factor authentication of user, e.g. when the device is lost
+// refresh tokens are revoked, so that the user must login again
+func (m *mservice) ResetMfa(context context.Context, userId string) error {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing ResetMfa, userId: %s", userId)
+	if aerror := appauth.Authorize(context, appauth.PermissionResetMfa, userId); aerror != nil {
+		return aerror
+	}
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return apperrors.NewInvalidArgument("invalid userid provided, userId: " + userId)
+	}
+	if dberror := m.cdbservice.ResetTotp(context, id); dberror != nil {
+		logger.Error(dberror)
+		return dberror
+	}
+	if _, rerror := m.rtdbservice.RevokeUserRefreshTokens(context, id, time.Now().UTC()); rerror != nil {
+		logger.Errorf("cannot revoke refresh tokens, userId: %s, error: %v", userId, rerror)
+	}
+	logger.Infof("Executed ResetMfa, userId: %s", userId)
+	return nil
+}
+
+func (m *mservice) getCredential(context context.Context, userId string) (*models.User, *dbmodel.CredentialSchema, error) {
+	user, dberror := m.dbservice.GetUserById(context, userId, []string{"_id", "email"})
+	if dberror != nil {
+		return nil, nil, dberror
+	}
+	credential, dberror := m.cdbservice.GetCredential(context, user.Id)
+	if apperrors.KindOf(dberror) == apperrors.NotFound {
+		return nil, nil, apperrors.NewConflict("password must be set before enabling two-factor authentication")
+	}
+	if dberror != nil {
+		return nil, nil, dberror
+	}
+	return user, credential, nil
+}
+
+// function to verify totp code or recovery code of login, used codes cannot be used again
+func verifySecondFactor(context context.Context, cdbservice db.CredentialDbService, cipher *appauth.SecretCipher, credential *dbmodel.CredentialSchema, code string) (bool, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	secret, derror := cipher.Decrypt(credential.TotpSecret, credential.UserId.Hex())
+	if derror != nil {
+		return false, apperrors.NewInternal("cannot decrypt totp secret", derror)
+	}
+	if step, valid := appauth.ValidateTotp(secret, code, time.Now()); valid {
+		used, dberror := cdbservice.UseTotpStep(context, credential.UserId, step)
+		if dberror != nil {
+			return false, dberror
+		}
+		if !used {
+			logger.Warnf("totp code replayed, userId: %s", credential.UserId.Hex())
+		}
+		return used, nil
+	}
+	if strings.Contains(code, "-") {
+		hash := cipher.Hash(strings.ToLower(strings.TrimSpace(code)), credential.UserId.Hex())
+		used, dberror := cdbservice.UseRecoveryCode(context, credential.UserId, hash)
+		if dberror != nil {
+			return false, dberror
+		}
+		if used {
+			logger.Warnf("recovery code used, userId: %s, remaining: %d", credential.UserId.Hex(), len(credential.RecoveryCodes)-1)
+		}
+		return used, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// function to compute the totp code of the step like an authenticator app, RFC 6238 with 6 digits
+func testTotpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// function to enroll and enable totp of the test user, the secret and recovery codes are returned
+func enableTestTotp(t *testing.T, test *authTest) (string, []string) {
+	t.Helper()
+	mfa := NewMfaService(test.users, test.creds, test.tokens, "issuer", test.cipher)
+	ctx := appauth.WithPrincipal(test.ctx, &appauth.Principal{Subject: test.user.Id.Hex(), Type: appauth.PrincipalTypeUser, Role: appauth.RoleUser})
+	enrollment, err := mfa.EnrollTotp(ctx, test.user.Id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := test.creds.credentials[test.user.Id].TotpPendingSecret
+	if strings.Contains(pending, enrollment.Secret) {
+		t.Fatalf("pending secret must be encrypted: %s", pending)
+	}
+	if _, err := mfa.VerifyTotp(ctx, test.user.Id.Hex(), "000000x"); apperrors.KindOf(err) != apperrors.InvalidArgument {
+		t.Fatalf("wrong codes must not enable totp: %v", err)
+	}
+	codes, err := mfa.VerifyTotp(ctx, test.user.Id.Hex(), testTotpCode(t, enrollment.Secret, time.Now().Unix()/30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential := test.creds.credentials[test.user.Id]
+	if !credential.TotpEnabled || strings.Contains(credential.TotpSecret, enrollment.Secret) {
+		t.Fatalf("totp must be enabled with the encrypted secret: %+v", credential)
+	}
+	for _, code := range codes.RecoveryCodes {
+		for _, hash := range credential.RecoveryCodes {
+			if strings.Contains(hash, code) {
+				t.Fatal("recovery codes must be stored as hashes")
+			}
+		}
+	}
+	return enrollment.Secret, codes.RecoveryCodes
+}
+
+func TestLoginWithTotpRejectsReplayedCodes(t *testing.T) {
+	test := newAuthTest(t, 5)
+	secret, _ := enableTestTotp(t, test)
+
+	_, err := test.login(testPassword)
+	expectUnauthorized(t, err)
+	if details := err.(*apperrors.AppError).Details; details["mfa_required"] != true {
+		t.Fatalf("login without code must require the one-time password: %v", details)
+	}
+
+	// the step of the verification is used, so the code of the next step is the first valid one
+	current := time.Now().Unix() / 30
+	_, err = test.loginWithOtp(testPassword, testTotpCode(t, secret, current))
+	expectUnauthorized(t, err)
+
+	code := testTotpCode(t, secret, current+1)
+	tokens, err := test.loginWithOtp(testPassword, code)
+	if err != nil {
+		t.Fatalf("login with the totp code must succeed: %v", err)
+	}
+	if len(tokens.AccessToken) == 0 {
+		t.Fatal("login must issue tokens")
+	}
+	_, err = test.loginWithOtp(testPassword, code)
+	expectUnauthorized(t, err)
+}
+
+func TestLoginWithRecoveryCodeOnce(t *testing.T) {
+	test := newAuthTest(t, 5)
+	_, codes := enableTestTotp(t, test)
+
+	if _, err := test.loginWithOtp(testPassword, " "+strings.ToUpper(codes[0])+" "); err != nil {
+		t.Fatalf("login with the recovery code must succeed: %v", err)
+	}
+	if remaining := len(test.creds.credentials[test.user.Id].RecoveryCodes); remaining != len(codes)-1 {
+		t.Fatalf("used recovery code must be removed, %d remaining", remaining)
+	}
+	_, err := test.loginWithOtp(testPassword, codes[0])
+	expectUnauthorized(t, err)
+	_, err = test.loginWithOtp(testPassword, "aaaaa-bbbbb-ccccc-ddddd")
+	expectUnauthorized(t, err)
+}
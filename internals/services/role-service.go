@@ -0,0 +1,55 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/db"
+	"GolangCourse/internals/models"
+	"context"
+)
+
+type RoleService interface {
+	GetRole(context context.Context, principal *appauth.Principal) (string, error)
+}
+
+type rservice struct {
+	dbservice db.DbService
+}
+
+func NewRoleService(dbservice db.DbService) RoleService {
+	return &rservice{
+		dbservice: dbservice,
+	}
+}
+
+// function to get role of principal, the role of a user is its type and users without type have user role
+// subject of user tokens must be the id of the user
+func (r *rservice) GetRole(context context.Context, principal *appauth.Principal) (string, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	if principal.Type == appauth.PrincipalTypeApiKey || principal.Type == appauth.PrincipalTypeCertificate {
+		return appauth.RoleService, nil
+	}
+	if principal.Type == appauth.PrincipalTypeSystem {
+		return appauth.RoleAdmin, nil
+	}
+	user, dberror := r.dbservice.GetUserById(context, principal.Subject, []string{"type"})
+	if dberror != nil {
+		logger.Error(dberror)
+		switch apperrors.KindOf(dberror) {
+		case apperrors.NotFound, apperrors.InvalidArgument:
+			return "", apperrors.NewForbidden("principal is not a known user")
+		}
+		return "", dberror
+	}
+	if len(user.Type) == 0 {
+		return models.UserTypeUser, nil
+	}
+	// admins must authenticate with a second factor, without it they only have the user role
+	// so that they can still enroll two-factor authentication
+	if user.Type == models.UserTypeAdmin && !principal.HasMfa() {
+		logger.Warnf("admin authenticated without second factor, using user role, principal: %s", principal)
+		return models.UserTypeUser, nil
+	}
+	return user.Type, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"GolangCourse/commons/apptracing"
+	"GolangCourse/internals/models"
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// user service which records a span of every operation
+type teservice struct {
+	delegate EventService
+}
+
+func newTracedEventService(delegate EventService) EventService {
+	return &teservice{delegate: delegate}
+}
+
+func startServiceSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return apptracing.Start(ctx, "EventService."+operation, trace.SpanKindInternal)
+}
+
+func (t *teservice) GetUserById(ctx context.Context, userId string, fields []string) (*models.User, error) {
+	ctx, span := startServiceSpan(ctx, "GetUserById")
+	user, err := t.delegate.GetUserById(ctx, userId, fields)
+	apptracing.End(span, err)
+	return user, err
+}
+
+func (t *teservice) GetUserByEmail(ctx context.Context, email string, fields []string) (*models.User, error) {
+	ctx, span := startServiceSpan(ctx, "GetUserByEmail")
+	user, err := t.delegate.GetUserByEmail(ctx, email, fields)
+	apptracing.End(span, err)
+	return user, err
+}
+
+func (t *teservice) DeleteUserById(ctx context.Context, userId string) error {
+	ctx, span := startServiceSpan(ctx, "DeleteUserById")
+	err := t.delegate.DeleteUserById(ctx, userId)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *teservice) GetUsers(ctx context.Context, filter *models.UserFilter, fields []string) ([]*models.User, error) {
+	ctx, span := startServiceSpan(ctx, "GetUsers")
+	users, err := t.delegate.GetUsers(ctx, filter, fields)
+	apptracing.End(span, err)
+	return users, err
+}
+
+func (t *teservice) ExportUsers(ctx context.Context, filter *models.UserFilter, fields []string, handler func(user *models.User) error) error {
+	ctx, span := startServiceSpan(ctx, "ExportUsers")
+	err := t.delegate.ExportUsers(ctx, filter, fields, handler)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *teservice) CreateUser(ctx context.Context, user *models.User) (string, error) {
+	ctx, span := startServiceSpan(ctx, "CreateUser")
+	id, err := t.delegate.CreateUser(ctx, user)
+	apptracing.End(span, err)
+	return id, err
+}
+
+func (t *teservice) UpdateUser(ctx context.Context, user *models.User, userId string) error {
+	ctx, span := startServiceSpan(ctx, "UpdateUser")
+	err := t.delegate.UpdateUser(ctx, user, userId)
+	apptracing.End(span, err)
+	return err
+}
+
+func (t *teservice) PatchUser(ctx context.Context, userId string, contentType string, patch []byte) (*models.User, error) {
+	ctx, span := startServiceSpan(ctx, "PatchUser")
+	user, err := t.delegate.PatchUser(ctx, userId, contentType, patch)
+	apptracing.End(span, err)
+	return user, err
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/commons/appvalidator"
+	"GolangCourse/internals/db"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultImportBatchSize = 500
+
+// user fields which can be populated by an import, keyed by json name, with the struct field name of validation
+var importFields = map[string]string{
+	"name":      "Name",
+	"email":     "Email",
+	"type":      "Type",
+	"age":       "Age",
+	"is_active": "IsActive",
+}
+
+type ImportService interface {
+	ImportUsers(context context.Context, source io.Reader, options *models.ImportOptions) (*models.ImportSummary, error)
+}
+
+type iservice struct {
+	dbservice db.DbService
+}
+
+func NewUserImportService(dbservice db.DbService) ImportService {
+	return &iservice{
+		dbservice: dbservice,
+	}
+}
+
+// pending row of the current batch, rows with insert error are only valid as update of an existing user
+type importRow struct {
+	line        int
+	record      map[string]string
+	user        *dbmodel.UserUpsert
+	insertError error
+}
+
+// function to stream users from csv or ndjson source, rows are validated and upserted by email in batches
+// rejected rows are reported in the summary and do not stop the import
+func (i *iservice) ImportUsers(context context.Context, source io.Reader, options *models.ImportOptions) (*models.ImportSummary, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing ImportUsers, format: %s", options.Format)
+	// imported rows can set the type of any user
+	for _, permission := range []appauth.Permission{appauth.PermissionWriteUsers, appauth.PermissionChangeType} {
+		if aerror := appauth.Authorize(context, permission, ""); aerror != nil {
+			return nil, aerror
+		}
+	}
+	for column, field := range options.Mapping {
+		if _, ok := importFields[field]; !ok {
+			return nil, apperrors.NewInvalidArgument(fmt.Sprintf("invalid mapping for column '%s', unknown user field: %s", column, field))
+		}
+	}
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	var reader func() (int, map[string]string, error)
+	switch options.Format {
+	case models.ImportFormatCsv:
+		csvReader, rerror := newCsvRowReader(source)
+		if rerror != nil {
+			logger.Error(rerror)
+			return nil, rerror
+		}
+		reader = csvReader
+	case models.ImportFormatNdjson:
+		reader = newNdjsonRowReader(source)
+	default:
+		return nil, apperrors.NewInvalidArgument(fmt.Sprintf("unsupported import format: %s", options.Format))
+	}
+
+	summary := &models.ImportSummary{}
+	batch := make([]*importRow, 0, batchSize)
+	// emails of the batch, rows of the same email are written in separate batches so that they do not race
+	batchEmails := map[string]bool{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		rows, ferror := i.filterUpdateOnlyRows(context, batch, summary)
+		if ferror != nil {
+			return ferror
+		}
+		users := make([]*dbmodel.UserUpsert, 0, len(rows))
+		for _, row := range rows {
+			users = append(users, row.user)
+		}
+		result, dberror := i.dbservice.UpsertUsersByEmail(context, users)
+		if dberror != nil {
+			return dberror
+		}
+		summary.Inserted += result.Inserted
+		summary.Updated += result.Updated
+		for index, reason := range result.Failures {
+			rejectRow(summary, rows[index].line, reason, rows[index].record)
+		}
+		batch = batch[:0]
+		clear(batchEmails)
+		return nil
+	}
+
+	for {
+		line, record, rerror := reader()
+		if rerror == io.EOF {
+			break
+		}
+		summary.Total++
+		if rerror != nil {
+			var rowError *importRowError
+			if !errors.As(rerror, &rowError) {
+				logger.Error(rerror)
+				return nil, rerror
+			}
+			rejectRow(summary, line, rowError.reason, record)
+			continue
+		}
+
+		row, verror := toImportRow(line, record, options.Mapping)
+		if verror != nil {
+			rejectRow(summary, line, verror.Error(), record)
+			continue
+		}
+		if batchEmails[row.user.User.Email] {
+			if ferror := flush(); ferror != nil {
+				logger.Error(ferror)
+				return nil, ferror
+			}
+		}
+		batchEmails[row.user.User.Email] = true
+		batch = append(batch, row)
+		if len(batch) >= batchSize {
+			if ferror := flush(); ferror != nil {
+				logger.Error(ferror)
+				return nil, ferror
+			}
+		}
+	}
+	if ferror := flush(); ferror != nil {
+		logger.Error(ferror)
+		return nil, ferror
+	}
+	sort.SliceStable(summary.Errors, func(a, b int) bool {
+		return summary.Errors[a].Line < summary.Errors[b].Line
+	})
+	logger.Infof("Executed ImportUsers, total: %d, inserted: %d, updated: %d, rejected: %d",
+		summary.Total, summary.Inserted, summary.Updated, summary.Rejected)
+	return summary, nil
+}
+
+// function to reject rows which are only valid as update when their user does not exist, remaining rows are returned
+func (i *iservice) filterUpdateOnlyRows(context context.Context, batch []*importRow, summary *models.ImportSummary) ([]*importRow, error) {
+	var emails []string
+	for _, row := range batch {
+		if row.user.UpdateOnly {
+			emails = append(emails, row.user.User.Email)
+		}
+	}
+	if len(emails) == 0 {
+		return batch, nil
+	}
+	users, dberror := i.dbservice.GetUsersByEmails(context, emails, []string{"email"})
+	if dberror != nil {
+		return nil, dberror
+	}
+	existing := make(map[string]bool, len(users))
+	for _, user := range users {
+		existing[models.NormalizeEmail(user.Email)] = true
+	}
+	rows := make([]*importRow, 0, len(batch))
+	for _, row := range batch {
+		if row.user.UpdateOnly && !existing[row.user.User.Email] {
+			rejectRow(summary, row.line, row.insertError.Error(), row.record)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// function to parse mapping of source columns to user fields, e.g. "Full Name:name,E-mail:email"
+func ParseImportMapping(value string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+		index := strings.LastIndex(pair, ":")
+		if index <= 0 {
+			return nil, apperrors.NewInvalidArgument(fmt.Sprintf("invalid mapping '%s', expected <column>:<field>", pair))
+		}
+		column, field := strings.TrimSpace(pair[:index]), strings.TrimSpace(pair[index+1:])
+		if _, ok := importFields[field]; !ok {
+			return nil, apperrors.NewInvalidArgument(fmt.Sprintf("invalid mapping '%s', unknown user field: %s", pair, field))
+		}
+		mapping[column] = field
+	}
+	return mapping, nil
+}
+
+// function to write the rejected rows of an import as csv report
+func WriteImportReport(writer io.Writer, summary *models.ImportSummary) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write([]string{"line", "reason", "record"}); err != nil {
+		return err
+	}
+	for _, rowError := range summary.Errors {
+		record, _ := json.Marshal(rowError.Record)
+		if err := csvWriter.Write([]string{strconv.Itoa(rowError.Line), rowError.Reason, string(record)}); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// function to record a rejected row in the import summary
+func rejectRow(summary *models.ImportSummary, line int, reason string, record map[string]string) {
+	summary.Rejected++
+	summary.Errors = append(summary.Errors, &models.ImportRowError{
+		Line:   line,
+		Reason: reason,
+		Record: record,
+	})
+}
+
+// error for a single malformed row, the import continues with the next row
+type importRowError struct {
+	reason string
+}
+
+func (e *importRowError) Error() string {
+	return e.reason
+}
+
+// function to create csv row reader, first record is used as header
+func newCsvRowReader(source io.Reader) (func() (int, map[string]string, error), error) {
+	csvReader := csv.NewReader(source)
+	csvReader.TrimLeadingSpace = true
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, apperrors.NewInvalidArgument("csv source is empty, header row is required")
+	}
+	if err != nil {
+		return nil, apperrors.NewInvalidArgument(fmt.Sprintf("invalid csv header: %v", err))
+	}
+	// strip utf-8 byte order mark written by spreadsheet exports
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	csvReader.FieldsPerRecord = len(header)
+
+	return func() (int, map[string]string, error) {
+		values, rerror := csvReader.Read()
+		if rerror == io.EOF {
+			return 0, nil, io.EOF
+		}
+		record := map[string]string{}
+		for index, value := range values {
+			if index < len(header) {
+				record[header[index]] = value
+			}
+		}
+		var parseError *csv.ParseError
+		if errors.As(rerror, &parseError) {
+			return parseError.StartLine, record, &importRowError{reason: parseError.Err.Error()}
+		}
+		line, _ := csvReader.FieldPos(0)
+		return line, record, rerror
+	}, nil
+}
+
+// function to create ndjson row reader, each non empty line is a json object
+func newNdjsonRowReader(source io.Reader) func() (int, map[string]string, error) {
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	return func() (int, map[string]string, error) {
+		for scanner.Scan() {
+			line++
+			content := bytes.TrimSpace(scanner.Bytes())
+			if len(content) == 0 {
+				continue
+			}
+			var values map[string]interface{}
+			decoder := json.NewDecoder(bytes.NewReader(content))
+			decoder.UseNumber()
+			if err := decoder.Decode(&values); err != nil {
+				return line, map[string]string{"raw": string(content)}, &importRowError{reason: "invalid json: " + err.Error()}
+			}
+			record := map[string]string{}
+			for key, value := range values {
+				if value != nil {
+					record[key] = fmt.Sprint(value)
+				}
+			}
+			return line, record, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return line, nil, apperrors.NewInvalidArgument(fmt.Sprintf("cannot read ndjson source: %v", err))
+		}
+		return line, nil, io.EOF
+	}
+}
+
+// function to map and validate a source record to the row with the user upsert, with the fields provided by the record
+// provided fields are validated, records which are not valid as new user can only update an existing user
+func toImportRow(line int, record map[string]string, mapping map[string]string) (*importRow, error) {
+	values := map[string]string{}
+	var fields []string
+	for column, value := range record {
+		field, ok := mapping[column]
+		if !ok {
+			// unmapped columns are used when they match a user field, e.g. "Email" or "is active"
+			field = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(column)), " ", "_")
+		}
+		if _, ok := importFields[field]; ok {
+			values[field] = strings.TrimSpace(value)
+			// empty values are not provided, so that they do not reset the values of existing users
+			if len(values[field]) > 0 {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	user := &models.UserProperties{
+		Name:  values["name"],
+		Email: values["email"],
+		Type:  values["type"],
+	}
+	if age := values["age"]; len(age) > 0 {
+		parsed, err := strconv.Atoi(age)
+		if err != nil {
+			return nil, fmt.Errorf("'age' must be a number: %s", age)
+		}
+		user.Age = parsed
+	}
+	if active := values["is_active"]; len(active) > 0 {
+		switch strings.ToLower(active) {
+		case "true", "1", "yes", "y":
+			user.IsActive = true
+		case "false", "0", "no", "n":
+			user.IsActive = false
+		default:
+			return nil, fmt.Errorf("'is_active' must be a boolean: %s", active)
+		}
+	}
+
+	// rows are validated with the same rules as the users api, email is the key of the row and always required
+	validated := []string{importFields["email"]}
+	for _, field := range fields {
+		if field != "email" {
+			validated = append(validated, importFields[field])
+		}
+	}
+	if verror := appvalidator.ValidateStructPartial(user, validated...); verror != nil {
+		return nil, toImportRowReason(verror)
+	}
+	var insertError error
+	if verror := appvalidator.ValidateStruct(user); verror != nil {
+		insertError = toImportRowReason(verror)
+	}
+	return &importRow{
+		line:   line,
+		record: record,
+		user: &dbmodel.UserUpsert{
+			User: &dbmodel.UserSchema{
+				Name:     user.Name,
+				Email:    models.NormalizeEmail(user.Email),
+				Type:     user.Type,
+				Age:      user.Age,
+				IsActive: user.IsActive,
+			},
+			Fields:     fields,
+			UpdateOnly: insertError != nil,
+		},
+		insertError: insertError,
+	}, nil
+}
+
+// function to convert validation error to the reason of the rejected row, e.g. "'age' must be at most 150"
+func toImportRowReason(verror error) error {
+	var appError *apperrors.AppError
+	if errors.As(verror, &appError) && len(appError.Details) > 0 {
+		reasons := make([]string, 0, len(appError.Details))
+		for field, message := range appError.Details {
+			reasons = append(reasons, fmt.Sprintf("'%s' %v", field, message))
+		}
+		sort.Strings(reasons)
+		return errors.New(strings.Join(reasons, "; "))
+	}
+	return verror
+}
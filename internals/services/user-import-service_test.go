@@ -0,0 +1,76 @@
+package services
+
+import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/models"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestImportUsersUpdatesProvidedFields(t *testing.T) {
+	ctx, _ := apploggers.NewLogger()
+	ctx = appauth.WithPrincipal(ctx, appauth.NewSystemPrincipal("test"))
+	ada := &models.User{Id: primitive.NewObjectID(), UserProperties: models.UserProperties{Name: "Ada", Email: "ada@example.com", Age: 36, IsActive: true}}
+	users := &fakeUserDbService{users: []*models.User{ada}}
+	source := strings.NewReader("E-mail,name,age\n" +
+		"ADA@example.com,,37\n" +
+		"new@example.com,,30\n" +
+		"not an email,Bob,30\n" +
+		"ada@example.com,,200\n")
+
+	summary, err := NewUserImportService(users).ImportUsers(ctx, source, &models.ImportOptions{
+		Format:  models.ImportFormatCsv,
+		Mapping: map[string]string{"E-mail": "email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Total != 4 || summary.Updated != 1 || summary.Inserted != 0 || summary.Rejected != 3 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	// the name is not provided, so that only the age of the existing user is updated
+	if ada.Name != "Ada" || ada.Age != 37 || !ada.IsActive {
+		t.Fatalf("only provided fields must be updated: %+v", ada)
+	}
+	// rows without name are not valid as new user, they are rejected when the user does not exist
+	lines := map[int]string{}
+	for _, rowError := range summary.Errors {
+		lines[rowError.Line] = rowError.Reason
+	}
+	for _, line := range []int{3, 4, 5} {
+		if len(lines[line]) == 0 {
+			t.Errorf("line %d must be rejected, got %v", line, lines)
+		}
+	}
+	if len(users.users) != 1 {
+		t.Fatalf("invalid rows must not insert users, got %d users", len(users.users))
+	}
+}
+
+func TestImportUsersWritesDuplicateEmailsInSeparateBatches(t *testing.T) {
+	ctx, _ := apploggers.NewLogger()
+	ctx = appauth.WithPrincipal(ctx, appauth.NewSystemPrincipal("test"))
+	users := &fakeUserDbService{}
+	source := strings.NewReader(`{"email":"bob@example.com","name":"Bob","age":30}
+{"email":"ann@example.com","name":"Ann"}
+{"email":"BOB@example.com","name":"Bobby"}
+`)
+
+	summary, err := NewUserImportService(users).ImportUsers(ctx, source, &models.ImportOptions{Format: models.ImportFormatNdjson})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Inserted != 2 || summary.Updated != 1 || summary.Rejected != 0 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if len(users.upserts) != 2 || len(users.upserts[0]) != 2 || len(users.upserts[1]) != 1 {
+		t.Fatalf("the duplicate email must be written in the next batch, got %d batches", len(users.upserts))
+	}
+	bob, _ := users.GetUserByEmail(ctx, "bob@example.com", nil)
+	if bob.Name != "Bobby" || bob.Age != 30 {
+		t.Fatalf("rows must be applied in order: %+v", bob)
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"GolangCourse/commons/apploggers"
+	"GolangCourse/internals/db"
+	dbmodel "GolangCourse/internals/db/models"
+	"GolangCourse/internals/models"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// users are counted at most once per interval, so that scrapes of several scrapers do not load the database
+	userMetricsInterval = 30 * time.Second
+	userMetricsTimeout  = 5 * time.Second
+)
+
+// collector of user metrics, users are counted on scrape
+type umcollector struct {
+	dbservice   db.DbService
+	users       *prometheus.Desc
+	activeUsers *prometheus.Desc
+
+	mux         sync.Mutex
+	counts      []*dbmodel.UserCount
+	collectedAt time.Time
+}
+
+func NewUserMetricsCollector(dbservice db.DbService) prometheus.Collector {
+	return &umcollector{
+		dbservice:   dbservice,
+		users:       prometheus.NewDesc("users", "Number of users.", nil, nil),
+		activeUsers: prometheus.NewDesc("users_active", "Number of active users by type.", []string{"type"}, nil),
+	}
+}
+
+func (u *umcollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- u.users
+	ch <- u.activeUsers
+}
+
+// function to collect user metrics, the last counts are reported when the users cannot be counted
+func (u *umcollector) Collect(ch chan<- prometheus.Metric) {
+	counts := u.getCounts()
+	if counts == nil {
+		return
+	}
+	total := int64(0)
+	active := map[string]int64{models.UserTypeAdmin: 0, models.UserTypeUser: 0, models.UserTypeGuest: 0}
+	for _, count := range counts {
+		total += count.Count
+		if count.IsActive {
+			// users without type are users
+			userType := count.Type
+			if len(userType) == 0 {
+				userType = models.UserTypeUser
+			}
+			active[userType] += count.Count
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(u.users, prometheus.GaugeValue, float64(total))
+	for userType, count := range active {
+		ch <- prometheus.MustNewConstMetric(u.activeUsers, prometheus.GaugeValue, float64(count), userType)
+	}
+}
+
+func (u *umcollector) getCounts() []*dbmodel.UserCount {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	if u.counts != nil && time.Since(u.collectedAt) < userMetricsInterval {
+		return u.counts
+	}
+	ctx, logger := apploggers.NewLoggerWithCorrelationid(context.Background(), "")
+	ctx, cancel := context.WithTimeout(ctx, userMetricsTimeout)
+	defer cancel()
+	counts, err := u.dbservice.CountUsers(ctx)
+	if err != nil {
+		logger.Warnf("cannot collect user metrics, error: %v", err)
+		return u.counts
+	}
+	if counts == nil {
+		counts = []*dbmodel.UserCount{}
+	}
+	u.counts = counts
+	u.collectedAt = time.Now()
+	return u.counts
+}
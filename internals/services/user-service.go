@@ -1,36 +1,52 @@
 package services
 
 import (
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/apperrors"
 	"GolangCourse/commons/apploggers"
+	"GolangCourse/commons/appvalidator"
 	"GolangCourse/internals/db"
 	dbmodel "GolangCourse/internals/db/models"
 	"GolangCourse/internals/models"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
 )
 
 type EventService interface {
-	GetUserById(context context.Context, userId string) (*models.User, error)
+	GetUserById(context context.Context, userId string, fields []string) (*models.User, error)
+	GetUserByEmail(context context.Context, email string, fields []string) (*models.User, error)
 	DeleteUserById(context context.Context, userId string) error
-	GetUsers(context context.Context) ([]*models.User, error)
+	GetUsers(context context.Context, filter *models.UserFilter, fields []string) ([]*models.User, error)
+	ExportUsers(context context.Context, filter *models.UserFilter, fields []string, handler func(user *models.User) error) error
 	CreateUser(context context.Context, user *models.User) (string, error)
 	UpdateUser(context context.Context, user *models.User, userId string) error
+	PatchUser(context context.Context, userId string, contentType string, patch []byte) (*models.User, error)
 }
 
 type eservice struct {
 	dbservice db.DbService
 }
 
+// function to create user service, operations of the service are traced
 func NewUserEventService(dbservice db.DbService) EventService {
-	return &eservice{
+	return newTracedEventService(&eservice{
 		dbservice: dbservice,
-	}
+	})
 }
 
-func (e *eservice) GetUserById(context context.Context, userId string) (*models.User, error) {
+func (e *eservice) GetUserById(context context.Context, userId string, fields []string) (*models.User, error) {
 	logger := apploggers.GetLoggerWithCorrelationid(context)
 	logger.Infof("Executing GetUserById, userId: %s", userId)
-	user, dberror := e.dbservice.GetUserById(context, userId)
+	if aerror := appauth.Authorize(context, appauth.PermissionReadUsers, userId); aerror != nil {
+		return nil, aerror
+	}
+	user, dberror := e.dbservice.GetUserById(context, userId, fields)
 	if dberror != nil {
 		logger.Error(dberror)
 		return nil, dberror
@@ -39,9 +55,39 @@ func (e *eservice) GetUserById(context context.Context, userId string) (*models.
 	return user, nil
 }
 
+func (e *eservice) GetUserByEmail(context context.Context, email string, fields []string) (*models.User, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing GetUserByEmail...")
+	// principals which can only read their own user are authorized before the lookup as well
+	// unknown emails are forbidden for them like emails of other users, so that registered emails are not disclosed
+	readUsers := appauth.IsAllowed(context, appauth.PermissionReadUsers)
+	if !readUsers {
+		if aerror := appauth.Authorize(context, appauth.PermissionReadSelf, ""); aerror != nil {
+			return nil, aerror
+		}
+	}
+	user, dberror := e.dbservice.GetUserByEmail(context, models.NormalizeEmail(email), fields)
+	if dberror != nil && !readUsers && apperrors.KindOf(dberror) == apperrors.NotFound {
+		logger.Warnf("authorization denied, principal: %s, permission: %s, email is not registered", appauth.GetPrincipal(context), appauth.PermissionReadUsers)
+		return nil, apperrors.NewForbidden("not allowed to " + string(appauth.PermissionReadUsers))
+	}
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	if aerror := appauth.Authorize(context, appauth.PermissionReadUsers, user.Id.Hex()); aerror != nil {
+		return nil, aerror
+	}
+	logger.Infof("Executed GetUserByEmail, userId: %s", user.Id.Hex())
+	return user, nil
+}
+
 func (e *eservice) DeleteUserById(context context.Context, userId string) error {
 	logger := apploggers.GetLoggerWithCorrelationid(context)
 	logger.Infof("Executing DeleteUserById, userId: %s", userId)
+	if aerror := appauth.Authorize(context, appauth.PermissionDeleteUsers, userId); aerror != nil {
+		return aerror
+	}
 	dberror := e.dbservice.DeleteUserById(context, userId)
 	if dberror != nil {
 		logger.Error(dberror)
@@ -51,10 +97,13 @@ func (e *eservice) DeleteUserById(context context.Context, userId string) error
 	return nil
 }
 
-func (e *eservice) GetUsers(context context.Context) ([]*models.User, error) {
+func (e *eservice) GetUsers(context context.Context, filter *models.UserFilter, fields []string) ([]*models.User, error) {
 	logger := apploggers.GetLoggerWithCorrelationid(context)
 	logger.Infof("Executing GetUsers...")
-	users, dberror := e.dbservice.GetUsers(context)
+	if aerror := appauth.Authorize(context, appauth.PermissionReadUsers, ""); aerror != nil {
+		return nil, aerror
+	}
+	users, dberror := e.dbservice.GetUsers(context, filter, fields)
 	if dberror != nil {
 		logger.Error(dberror)
 		return nil, dberror
@@ -63,20 +112,49 @@ func (e *eservice) GetUsers(context context.Context) ([]*models.User, error) {
 	return users, nil
 }
 
+func (e *eservice) ExportUsers(context context.Context, filter *models.UserFilter, fields []string, handler func(user *models.User) error) error {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing ExportUsers...")
+	if aerror := appauth.Authorize(context, appauth.PermissionReadUsers, ""); aerror != nil {
+		return aerror
+	}
+	dberror := e.dbservice.StreamUsers(context, filter, fields, handler)
+	if dberror != nil {
+		logger.Error(dberror)
+		return dberror
+	}
+	logger.Infof("Executed ExportUsers")
+	return nil
+}
+
 func (e *eservice) CreateUser(context context.Context, user *models.User) (string, error) {
 	logger := apploggers.GetLoggerWithCorrelationid(context)
 	logger.Infof("Executing CreateUser...")
+	if aerror := appauth.Authorize(context, appauth.PermissionWriteUsers, ""); aerror != nil {
+		return "", aerror
+	}
+	// users without type have the user role, other types are granted like changes of the type
+	if len(user.Type) > 0 && user.Type != models.UserTypeUser {
+		if aerror := appauth.Authorize(context, appauth.PermissionChangeType, ""); aerror != nil {
+			return "", aerror
+		}
+	}
+	user.Email = models.NormalizeEmail(user.Email)
+	if cerror := e.checkEmailAvailable(context, user.Email, ""); cerror != nil {
+		logger.Error(cerror)
+		return "", cerror
+	}
 	var userSchema *dbmodel.UserSchema
 	pbyes, _ := json.Marshal(user)
 	uerror := json.Unmarshal(pbyes, &userSchema)
 	if uerror != nil {
 		logger.Error(uerror.Error())
-		return "", uerror
+		return "", apperrors.NewInternal("cannot create user", uerror)
 	}
 	userId, dberror := e.dbservice.SaveUser(context, userSchema)
 	if dberror != nil {
 		logger.Error(dberror)
-		return "", dberror
+		return "", e.withConflictingUser(context, dberror, user.Email)
 	}
 	logger.Infof("Executed CreateUser, userId: %v", userId)
 	return userId, nil
@@ -85,18 +163,167 @@ func (e *eservice) CreateUser(context context.Context, user *models.User) (strin
 func (e *eservice) UpdateUser(context context.Context, user *models.User, userId string) error {
 	logger := apploggers.GetLoggerWithCorrelationid(context)
 	logger.Infof("Executing UpdateUser...")
+	if aerror := appauth.Authorize(context, appauth.PermissionWriteUsers, userId); aerror != nil {
+		return aerror
+	}
+	if aerror := e.authorizeTypeChange(context, userId, user.Type); aerror != nil {
+		return aerror
+	}
+	user.Email = models.NormalizeEmail(user.Email)
+	if cerror := e.checkEmailAvailable(context, user.Email, userId); cerror != nil {
+		logger.Error(cerror)
+		return cerror
+	}
 	var userSchema *dbmodel.UserSchema
 	pbyes, _ := json.Marshal(user)
 	uerror := json.Unmarshal(pbyes, &userSchema)
 	if uerror != nil {
 		logger.Error(uerror.Error())
-		return uerror
+		return apperrors.NewInternal("cannot update user", uerror)
 	}
 	dberror := e.dbservice.UpdateUser(context, userSchema, userId)
 	if dberror != nil {
 		logger.Error(dberror)
-		return dberror
+		return e.withConflictingUser(context, dberror, user.Email)
 	}
 	logger.Infof("Executed UpdateUser, userId: %v", userId)
 	return nil
 }
+
+// function to apply json merge patch or json patch to the user, only the changed fields are updated
+func (e *eservice) PatchUser(context context.Context, userId string, contentType string, patch []byte) (*models.User, error) {
+	logger := apploggers.GetLoggerWithCorrelationid(context)
+	logger.Infof("Executing PatchUser, userId: %s, patch: %s", userId, contentType)
+	if aerror := appauth.Authorize(context, appauth.PermissionWriteUsers, userId); aerror != nil {
+		return nil, aerror
+	}
+	current, dberror := e.dbservice.GetUserById(context, userId, nil)
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, dberror
+	}
+	document, _ := json.Marshal(current)
+
+	var patched []byte
+	var perror error
+	switch contentType {
+	case models.MergePatchContentType:
+		patched, perror = jsonpatch.MergePatch(document, patch)
+	case models.JsonPatchContentType:
+		operations, derror := jsonpatch.DecodePatch(patch)
+		if derror != nil {
+			perror = derror
+			break
+		}
+		patched, perror = operations.Apply(document)
+	default:
+		return nil, apperrors.New(apperrors.UnsupportedMediaType, fmt.Sprintf("unsupported patch content type: %s", contentType))
+	}
+	if errors.Is(perror, jsonpatch.ErrTestFailed) {
+		logger.Error(perror)
+		return nil, apperrors.NewConflict(fmt.Sprintf("cannot apply patch: %v", perror))
+	}
+	if perror != nil {
+		logger.Error(perror)
+		return nil, apperrors.NewInvalidArgument(fmt.Sprintf("cannot apply patch: %v", perror))
+	}
+
+	// patched document must still be a valid user
+	var patchedFields map[string]interface{}
+	if err := json.Unmarshal(patched, &patchedFields); err != nil {
+		return nil, apperrors.NewInvalidArgument("cannot apply patch, patched document must be an object")
+	}
+	for field := range patchedFields {
+		if !slices.Contains(models.UserFields, field) {
+			return nil, apperrors.NewInvalidArgument(fmt.Sprintf("cannot apply patch, unknown field: %s", field))
+		}
+	}
+	var user *models.User
+	if err := json.Unmarshal(patched, &user); err != nil {
+		return nil, apperrors.NewInvalidArgument(fmt.Sprintf("cannot apply patch, invalid user: %v", err))
+	}
+	if user.Id != current.Id {
+		return nil, apperrors.NewInvalidArgument("cannot apply patch, '_id' cannot be changed")
+	}
+	if verror := appvalidator.ValidateStruct(user); verror != nil {
+		return nil, verror
+	}
+	user.Email = models.NormalizeEmail(user.Email)
+
+	changes := map[string]interface{}{}
+	for _, field := range models.UserFields {
+		if field == "_id" {
+			continue
+		}
+		if value := user.FieldValue(field); !reflect.DeepEqual(value, current.FieldValue(field)) {
+			changes[field] = value
+		}
+	}
+	if _, ok := changes["type"]; ok {
+		if aerror := appauth.Authorize(context, appauth.PermissionChangeType, userId); aerror != nil {
+			return nil, aerror
+		}
+	}
+	if _, ok := changes["email"]; ok {
+		if cerror := e.checkEmailAvailable(context, user.Email, userId); cerror != nil {
+			logger.Error(cerror)
+			return nil, cerror
+		}
+	}
+	dberror = e.dbservice.UpdateUserFields(context, userId, changes)
+	if dberror != nil {
+		logger.Error(dberror)
+		return nil, e.withConflictingUser(context, dberror, user.Email)
+	}
+	logger.Infof("Executed PatchUser, userId: %s, changed fields: %d", userId, len(changes))
+	return user, nil
+}
+
+// function to authorize change of user type, the current type is only loaded when the principal cannot change types
+func (e *eservice) authorizeTypeChange(context context.Context, userId string, userType string) error {
+	if appauth.IsAllowed(context, appauth.PermissionChangeType) {
+		return nil
+	}
+	current, dberror := e.dbservice.GetUserById(context, userId, []string{"type"})
+	if dberror != nil {
+		return dberror
+	}
+	if current.Type == userType {
+		return nil
+	}
+	return appauth.Authorize(context, appauth.PermissionChangeType, userId)
+}
+
+// function to check that no other user has the normalised email
+// conflict error contains the id of the existing user
+func (e *eservice) checkEmailAvailable(context context.Context, email string, userId string) error {
+	existing, dberror := e.dbservice.GetUserByEmail(context, email, []string{"_id"})
+	if apperrors.KindOf(dberror) == apperrors.NotFound {
+		return nil
+	}
+	if dberror != nil {
+		return dberror
+	}
+	if existing.Id.Hex() == userId {
+		return nil
+	}
+	return emailConflict(existing.Id.Hex())
+}
+
+// function to add the id of the existing user to duplicate email errors of concurrent writes
+func (e *eservice) withConflictingUser(context context.Context, dberror error, email string) error {
+	if apperrors.KindOf(dberror) != apperrors.Conflict {
+		return dberror
+	}
+	existing, gerror := e.dbservice.GetUserByEmail(context, email, []string{"_id"})
+	if gerror != nil {
+		return dberror
+	}
+	return emailConflict(existing.Id.Hex())
+}
+
+func emailConflict(conflictingId string) error {
+	return apperrors.NewConflict("user with the same email already exists").WithDetails(map[string]interface{}{
+		"conflicting_id": conflictingId,
+	})
+}
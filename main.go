@@ -3,11 +3,28 @@ package main
 import (
 	"GolangCourse/apis"
 	_ "GolangCourse/apis/docs"
+	"GolangCourse/commands"
+	"GolangCourse/commons/appauth"
+	"GolangCourse/commons/appdb"
+	"GolangCourse/commons/appfeatures"
 	"GolangCourse/commons/apploggers"
+	"GolangCourse/commons/appmetrics"
+	"GolangCourse/commons/appratelimit"
+	"GolangCourse/commons/apptls"
+	"GolangCourse/commons/apptracing"
+	"GolangCourse/commons/appvalidator"
 	"GolangCourse/configs"
 	"GolangCourse/internals/db"
 	"GolangCourse/internals/services"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/swaggo/echo-swagger"
@@ -20,31 +37,292 @@ import (
 // @contact.email support@example.com
 // @host localhost:3000
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT bearer token, e.g. "Bearer {token}"
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description API key of machine clients, e.g. "ApiKey {key}"
 func main() {
 	context, logger := apploggers.NewLoggerWithCorrelationid(context.Background(), "")
-	err := configs.NewApplicationConfig(context)
+	// configuration errors are printed without logger, so that all invalid values are readable
+	config, args, err := configs.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		logger.Errorf("Error in Appconfig:", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// configuration is printed before the connection, so that it can be checked without database
+	if len(args) > 0 && args[0] == "config" {
+		if cerror := commands.RunConfig(config, args[1:]); cerror != nil {
+			fmt.Fprintln(os.Stderr, cerror)
+			os.Exit(1)
+		}
+		return
+	}
+	// loggers are bound to the outputs on use, so the startup logger writes to the configured outputs as well
+	if lerror := apploggers.Configure(config.LoggerConfig()); lerror != nil {
+		logger.Fatalf("cannot configure logger: %v", lerror)
 	}
+	if terror := apptracing.Configure(context, config.TracerConfig()); terror != nil {
+		logger.Fatalf("cannot configure tracing: %v", terror)
+	}
+	appfeatures.Set(config.Features)
+	logger.Infof("loaded configuration of profile %s", config.Profile)
 
-	dbservice := db.NewUserDbService(configs.AppConfig.DbClient)
+	dbClient, derror := appdb.Connect(context, config.DbConfig())
+	if derror != nil {
+		logger.Fatalf("cannot connect to database: %v", derror)
+	}
+
+	dbservice := db.NewUserDbService(dbClient)
+	// emails are normalised before the unique email index is created, as stored duplicates prevent the index
+	if len(args) > 0 && args[0] == "normalize-emails" {
+		emailMigrationService := services.NewEmailMigrationService(dbservice)
+		exitCode := 0
+		if err := commands.RunNormalizeEmails(appauth.WithPrincipal(context, appauth.NewSystemPrincipal("normalize-emails-command")), emailMigrationService, args[1:]); err != nil {
+			logger.Errorf("normalize emails failed: %v", err)
+			exitCode = 1
+		}
+		shutdown(context, nil, dbClient, config.Http.ShutdownTimeout)
+		os.Exit(exitCode)
+	}
+	if ierror := dbservice.EnsureIndexes(context); ierror != nil {
+		logger.Fatalf("users indexes are not created, emails are not unique, run the normalize-emails command to resolve duplicate emails, error: %v", ierror)
+	}
+	apiKeyDbService := db.NewApiKeyDbService(dbClient)
+	if ierror := apiKeyDbService.EnsureIndexes(context); ierror != nil {
+		logger.Warnf("api keys indexes are not created, error: %v", ierror)
+	}
+	credentialDbService := db.NewCredentialDbService(dbClient)
+	if ierror := credentialDbService.EnsureIndexes(context); ierror != nil {
+		logger.Warnf("credentials indexes are not created, error: %v", ierror)
+	}
+	refreshTokenDbService := db.NewRefreshTokenDbService(dbClient)
+	if ierror := refreshTokenDbService.EnsureIndexes(context); ierror != nil {
+		logger.Warnf("refresh tokens indexes are not created, error: %v", ierror)
+	}
 	eventService := services.NewUserEventService(dbservice)
+	apiKeyService := services.NewApiKeyService(apiKeyDbService)
+	roleService := services.NewRoleService(dbservice)
+	importService := services.NewUserImportService(dbservice)
+
+	// run command instead of http server, e.g. "go run . import --file users.csv"
+	if len(args) > 0 {
+		exitCode := 0
+		switch args[0] {
+		case "import":
+			// commands are run by the operator, they are authorized as the system principal
+			if err := commands.RunImport(appauth.WithPrincipal(context, appauth.NewSystemPrincipal("import-command")), importService, args[1:]); err != nil {
+				logger.Errorf("import failed: %v", err)
+				exitCode = 1
+			}
+		default:
+			logger.Errorf("unknown command: %s", args[0])
+			exitCode = 1
+		}
+		shutdown(context, nil, dbClient, config.Http.ShutdownTimeout)
+		os.Exit(exitCode)
+	}
+
+	// log, rate limits, cors and features are reloaded on SIGHUP and on changes of the config files
+	reloader := configs.NewReloader(config, os.Args[1:])
+	reloader.OnReload(func(previous *configs.Config, next *configs.Config) (*configs.ReloadChange, error) {
+		if reflect.DeepEqual(previous.Log, next.Log) {
+			return nil, nil
+		}
+		prepared, perror := apploggers.Prepare(next.LoggerConfig())
+		if perror != nil {
+			return nil, perror
+		}
+		return &configs.ReloadChange{Apply: prepared.Apply, Discard: prepared.Discard}, nil
+	})
+	reloader.OnReload(func(previous *configs.Config, next *configs.Config) (*configs.ReloadChange, error) {
+		return &configs.ReloadChange{Apply: func() { appfeatures.Set(next.Features) }}, nil
+	})
+	if werror := reloader.Watch(context); werror != nil {
+		logger.Warnf("config files are not watched, configuration is reloaded on SIGHUP only, error: %v", werror)
+	}
 
 	// Echo instance
 	e := echo.New()
+	e.HTTPErrorHandler = apis.HttpErrorHandler
+	// client ip is only taken from X-Forwarded-For of proxies in private networks, so that clients cannot spoof it
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	e.Use(apis.CorrelationMiddleware())
+	e.Use(apis.CorsMiddleware(func() []string { return reloader.Current().Cors.AllowOrigins }))
+	e.Use(apis.TracingMiddleware())
+	e.Use(apis.AccessLogMiddleware(config.AccessLog.Exclude, config.AccessLog.SampleRate))
+	e.Use(apis.MetricsMiddleware())
+	// panics are recovered inside access log and metrics, so that the failed request is logged and counted
+	e.Use(apis.RecoverMiddleware())
+	// internal callers must authenticate with client certificates, after access log so that rejected callers are logged
+	e.Use(apis.MtlsMiddleware(config.MtlsNetworks()))
+	// body sizes and deadlines per route, the deadline is propagated to services and db operations
+	e.Use(apis.BodyLimitMiddleware(config.BodyLimits()))
+	e.Use(apis.DeadlineMiddleware(config.RequestTimeouts()))
+	// all requests of a client ip are limited before authentication, so that guessing of credentials is limited
+	limiter := appratelimit.NewLimiter()
+	rateLimits := func() *appratelimit.Config { return reloader.Current().RateLimits() }
+	e.Use(apis.ClientIpRateLimitMiddleware(limiter, rateLimits))
+	e.Validator = appvalidator.NewValidator()
+
+	// authentication of user and admin api, disabled only for local development with AUTH_DISABLED=true
+	users := e.Group("/users")
+	admin := e.Group("/admin")
+	debug := e.Group("/debug")
+	if config.Auth.Disabled {
+		logger.Warn("authentication is disabled, user, admin and debug api are not protected")
+		localMiddleware := apis.LocalPrincipalMiddleware()
+		users.Use(localMiddleware)
+		admin.Use(localMiddleware)
+		debug.Use(localMiddleware)
+	} else {
+		verifier, verror := appauth.NewJwtVerifier(config.AuthJwtConfig())
+		if verror != nil {
+			logger.Fatalf("cannot create jwt verifier: %v", verror)
+		}
+		certificates, cerror := appauth.NewCertificateAuthenticator(config.Tls.ClientPrincipals)
+		if cerror != nil {
+			logger.Fatalf("cannot create client certificate authenticator: %v", cerror)
+		}
+		authMiddleware := apis.AuthMiddleware(verifier, apiKeyService, certificates)
+		roleMiddleware := apis.RoleMiddleware(roleService)
+		users.Use(authMiddleware, roleMiddleware)
+		admin.Use(authMiddleware, roleMiddleware)
+		debug.Use(authMiddleware, roleMiddleware)
+	}
+
+	// rate limits per client, after authentication so that api keys and users are limited separately
+	rateLimitMiddleware := apis.RateLimitMiddleware(limiter, rateLimits)
+	users.Use(rateLimitMiddleware)
+	admin.Use(rateLimitMiddleware)
+	debug.Use(rateLimitMiddleware)
 
 	// user api Routes
 	userController := apis.NewUserController(eventService)
-	e.GET("/users", userController.GetUsers)
-	e.GET("/users/:id", userController.GetUserById)
-	e.DELETE("/users/:id", userController.DeleteUserById)
-	e.POST("/users", userController.CreateUser)
-	e.PATCH("/users/:id", userController.UpdateUser)
+	users.GET("", userController.GetUsers)
+	users.GET("/export", userController.ExportUsers, apis.RequireFeature(appfeatures.FeatureUserExport))
+	users.GET("/:id", userController.GetUserById)
+	users.GET("/by-email/:email", userController.GetUserByEmail)
+	users.DELETE("/:id", userController.DeleteUserById)
+	users.POST("", userController.CreateUser)
+	users.PATCH("/:id", userController.UpdateUser)
+	users.PUT("/:id", userController.ReplaceUser)
+
+	// user import api Routes
+	importController := apis.NewUserImportController(importService)
+	users.POST("/import", importController.ImportUsers, apis.RequireFeature(appfeatures.FeatureUserImport))
+
+	// authentication api Routes, only available when tokens can be signed
+	signer, serror := appauth.NewJwtSigner(config.AuthJwtConfig())
+	if serror != nil {
+		logger.Warnf("login is disabled, jwt signing key is not configured, error: %v", serror)
+	} else {
+		// totp secrets are encrypted at rest, logins of users with two-factor authentication decrypt them
+		totpCipher, cerror := appauth.LoadSecretCipher(config.Auth.TotpKeyFile)
+		if cerror != nil {
+			logger.Fatalf("cannot load totp key: %v", cerror)
+		}
+		authService := services.NewAuthService(dbservice, credentialDbService, refreshTokenDbService, signer, &services.AuthOptions{
+			AccessTokenTtl:   config.Auth.AccessTokenTtl,
+			RefreshTokenTtl:  config.Auth.RefreshTokenTtl,
+			PasswordPolicy:   config.PasswordPolicy(),
+			LockoutThreshold: config.Auth.LockoutThreshold,
+			LockoutDuration:  config.Auth.LockoutDuration,
+			TotpCipher:       totpCipher,
+		})
+		authController := apis.NewAuthController(authService)
+		auth := e.Group("/auth", rateLimitMiddleware)
+		auth.POST("/login", authController.Login)
+		auth.POST("/refresh", authController.Refresh)
+		auth.POST("/logout", authController.Logout)
+		users.PUT("/:id/password", authController.SetPassword)
+
+		mfaService := services.NewMfaService(dbservice, credentialDbService, refreshTokenDbService, config.Auth.TotpIssuer, totpCipher)
+		mfaController := apis.NewMfaController(mfaService)
+		users.POST("/:id/mfa/totp", mfaController.EnrollTotp)
+		users.POST("/:id/mfa/totp/verify", mfaController.VerifyTotp)
+		users.DELETE("/:id/mfa", mfaController.ResetMfa)
+	}
+
+	// api key admin api Routes
+	apiKeyController := apis.NewApiKeyController(apiKeyService)
+	manageApiKeys := apis.RequirePermission(appauth.PermissionManageApiKeys)
+	admin.GET("/api-keys", apiKeyController.GetApiKeys, manageApiKeys)
+	admin.POST("/api-keys", apiKeyController.CreateApiKey, manageApiKeys)
+	admin.DELETE("/api-keys/:id", apiKeyController.RevokeApiKey, manageApiKeys)
+
+	// log level admin api Routes, levels are changed only on the instance which serves the request
+	logLevelController := apis.NewLogLevelController(services.NewLogLevelService())
+	manageLogs := apis.RequirePermission(appauth.PermissionManageLogs)
+	admin.GET("/log-levels", logLevelController.GetLogLevels, manageLogs)
+	admin.PUT("/log-levels", logLevelController.SetLogLevels, manageLogs)
+
+	// recent logs of the instance which serves the request, e.g. to find the logs of a failed request
+	debugLogController := apis.NewDebugLogController(services.NewDebugLogService())
+	debug.GET("/logs", debugLogController.GetRecentLogs, apis.RequirePermission(appauth.PermissionReadLogs))
+
+	// prometheus metrics route, users are counted on scrape
+	if merror := appmetrics.Register(services.NewUserMetricsCollector(dbservice)); merror != nil {
+		logger.Fatalf("cannot register user metrics: %v", merror)
+	}
+	e.GET("/metrics", echo.WrapHandler(appmetrics.Handler()), apis.MetricsTokenMiddleware(config.Metrics.Token))
 
 	// Swagger UI route
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	// Start server
-	logger.Infof("starting http server on localhost:%v", configs.AppConfig.HttpPort)
-	e.Logger.Fatal(e.Start(":" + configs.AppConfig.HttpPort))
+	// https with http/2 when a certificate is configured, rotated certificates are loaded without restart
+	server := config.HttpServer()
+	if tlsConfig := config.ServerTlsConfig(); tlsConfig != nil {
+		tlsServer, terror := apptls.NewServer(context, tlsConfig)
+		if terror != nil {
+			logger.Fatalf("cannot configure https: %v", terror)
+		}
+		server.TLSConfig = tlsServer.TlsConfig()
+		logger.Infof("starting https server on localhost:%v, client certificates: %s", config.Http.Port, tlsConfig.ClientAuth)
+	} else {
+		logger.Infof("starting http server on localhost:%v", config.Http.Port)
+	}
+	// server is shut down on SIGINT and SIGTERM, in-flight requests are completed before the spans are exported
+	signalContext, stop := signal.NotifyContext(context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- e.StartServer(server)
+	}()
+	exitCode := 0
+	select {
+	case serror := <-serverErrors:
+		logger.Errorf("server stopped: %v", serror)
+		exitCode = 1
+	case <-signalContext.Done():
+		logger.Info("shutdown signal received, stopping server")
+	}
+	shutdown(context, e, dbClient, config.Http.ShutdownTimeout)
+	os.Exit(exitCode)
+}
+
+// function to stop the server and export pending spans before exit, as deferred calls are not run by os.Exit
+// requests which are still running after the timeout are cancelled
+func shutdown(ctx context.Context, e *echo.Echo, dbClient appdb.DatabaseClient, timeout time.Duration) {
+	logger := apploggers.GetLoggerWithCorrelationid(ctx)
+	shutdownContext, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if e != nil {
+		if serror := e.Shutdown(shutdownContext); serror != nil {
+			logger.Warnf("server is not shut down gracefully: %v", serror)
+		}
+	}
+	dbClient.Disconnect(shutdownContext)
+	if terror := apptracing.Shutdown(shutdownContext); terror != nil {
+		logger.Warnf("pending spans are not exported: %v", terror)
+	}
+	logger.Info("shutdown completed")
+	_ = apploggers.Sync()
 }